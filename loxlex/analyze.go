@@ -0,0 +1,31 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// analyses are the static analyses run by analyze, in order. Each one
+// returns the diagnostics it finds in a program. The resolver runs
+// first, so that the analyses after it can use the depths of the
+// variables.
+var analyses = []func(stmts []ast.Stmt) []Diagnostic{
+	resolve,
+	unreachable,
+}
+
+// analyze runs the static analyses on the program made of stmts,
+// which must be syntactically correct, and returns the diagnostics
+// found, sorted by position.
+func analyze(stmts []ast.Stmt) []Diagnostic {
+	var diags []Diagnostic
+	for _, a := range analyses {
+		diags = append(diags, a(stmts)...)
+	}
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return cmp.Compare(a.Pos.Offset, b.Pos.Offset)
+	})
+	return diags
+}
@@ -0,0 +1,305 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An ApplyFunc is invoked by [Apply] for each node n, even if n is
+// nil, before and/or after the node's children, using a [Cursor]
+// describing the current node and providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal.
+// See Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root, and
+// calling pre and post for each node as described below. Apply returns
+// the syntax tree, possibly modified.
+//
+// If pre is not nil, it is called for each node before the node's
+// children are traversed (pre-order). If pre returns false, no
+// children are traversed, and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre didn't return false,
+// post is called for each node after its children are traversed
+// (post-order). If post returns false, traversal is terminated and
+// Apply returns immediately.
+//
+// Only fields that refer to nodes are traversed, in the same order as
+// [Walk], including the optional fields that are nil.
+//
+// Children of a node may be modified by pre or post. If the current
+// node is replaced by pre, the children of the old node are still
+// traversed, and the new node is not. To rewrite the statements of a
+// whole program, apply a [Block] that contains them.
+//
+// This is a port of Apply of golang.org/x/tools/go/ast/astutil.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	parent := &struct{ Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during [Apply]. Information
+// about the node and its parent is available from the Node, Parent,
+// Name, and Index methods.
+//
+// If p is a variable of type and value of the current parent node
+// c.Parent(), and f is the field identifier with name c.Name(), the
+// following invariants hold:
+//
+//	p.f            == c.Node()  if c.Index() <  0
+//	p.f[c.Index()] == c.Node()  if c.Index() >= 0
+//
+// The methods Replace, Delete, InsertBefore, and InsertAfter can be
+// used to change the syntax tree through the cursor.
+type Cursor struct {
+	parent Node
+	name   string
+	iter   *iterator // valid if non-nil
+	node   Node
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the parent node field that contains the
+// current node. If the parent is a *Block and the current node is a
+// statement, c.Name() returns "Stmts".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current node in the slice of
+// nodes that contains it, or a value < 0 if the current node is not
+// part of a slice. The index of the current node changes if
+// InsertBefore is called while processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the current node's parent field value.
+func (c *Cursor) field() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+}
+
+// Replace replaces the current node with n. The replacement node is
+// not walked by Apply.
+func (c *Cursor) Replace(n Node) {
+	v := c.field()
+	if i := c.Index(); i >= 0 {
+		v = v.Index(i)
+	}
+	if n == nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(reflect.ValueOf(n))
+	}
+	c.node = n
+}
+
+// Delete deletes the current node from its containing slice. If the
+// current node is not part of a slice, Delete panics.
+func (c *Cursor) Delete() {
+	i := c.Index()
+	if i < 0 {
+		panic("Delete node not contained in slice")
+	}
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current node in its containing
+// slice. If the current node is not part of a slice, InsertAfter
+// panics. Apply does not walk n.
+func (c *Cursor) InsertAfter(n Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertAfter node not contained in slice")
+	}
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+2, l), v.Slice(i+1, l))
+	v.Index(i + 1).Set(reflect.ValueOf(n))
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current node in its containing
+// slice. If the current node is not part of a slice, InsertBefore
+// panics. Apply will not walk n.
+func (c *Cursor) InsertBefore(n Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertBefore node not contained in slice")
+	}
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+1, l), v.Slice(i, l))
+	v.Index(i).Set(reflect.ValueOf(n))
+	c.iter.index++
+}
+
+// application carries all the shared data so we can pass it around
+// cheaply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent Node, name string, iter *iterator, n Node) {
+	// Convert typed nil into untyped nil.
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Pointer && v.IsNil() {
+		n = nil
+	}
+
+	// Avoid heap-allocating a new cursor for each apply call; reuse
+	// a.cursor instead.
+	saved := a.cursor
+	a.cursor.parent = parent
+	a.cursor.name = name
+	a.cursor.iter = iter
+	a.cursor.node = n
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// Walk children.
+	switch n := n.(type) {
+	case nil:
+		// Nothing to do.
+
+	case *Ident:
+		// Nothing to do.
+
+	case *Assign:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Value", nil, n.Value)
+	case *Binary:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Y", nil, n.Y)
+	case *Call:
+		a.apply(n, "Callee", nil, n.Callee)
+		a.applyList(n, "Args")
+	case *Conditional:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Then", nil, n.Then)
+		a.apply(n, "Else", nil, n.Else)
+	case *Get:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Name", nil, n.Name)
+	case *Grouping:
+		a.apply(n, "X", nil, n.X)
+	case *Literal:
+		// Nothing to do.
+	case *Logical:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Y", nil, n.Y)
+	case *Set:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Value", nil, n.Value)
+	case *Super:
+		a.apply(n, "Method", nil, n.Method)
+	case *This:
+		// Nothing to do.
+	case *Unary:
+		a.apply(n, "X", nil, n.X)
+	case *Variable:
+		a.apply(n, "Name", nil, n.Name)
+
+	case *Block:
+		a.applyList(n, "Stmts")
+	case *Class:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Superclass", nil, n.Superclass)
+		a.applyList(n, "Methods")
+	case *Expression:
+		a.apply(n, "X", nil, n.X)
+	case *For:
+		a.apply(n, "Init", nil, n.Init)
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Incr", nil, n.Incr)
+		a.apply(n, "Body", nil, n.Body)
+	case *Fun:
+		a.apply(n, "Name", nil, n.Name)
+		a.applyList(n, "Params")
+		a.apply(n, "Body", nil, n.Body)
+	case *If:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Then", nil, n.Then)
+		a.apply(n, "Else", nil, n.Else)
+	case *Print:
+		a.apply(n, "X", nil, n.X)
+	case *Return:
+		a.apply(n, "Value", nil, n.Value)
+	case *Var:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Init", nil, n.Init)
+	case *While:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Body", nil, n.Body)
+
+	default:
+		panic(fmt.Sprintf("ast.Apply: unexpected node %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+// An iterator controls iteration over a slice of nodes.
+type iterator struct {
+	index, step int
+}
+
+func (a *application) applyList(parent Node, name string) {
+	// Avoid heap-allocating a new iterator for each applyList call;
+	// reuse a.iter instead.
+	saved := a.iter
+	a.iter.index = 0
+	for {
+		// Must reload parent.name each time, since cursor
+		// modifications might change it.
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if a.iter.index >= v.Len() {
+			break
+		}
+
+		// Element x may be nil in a bad syntax tree; be cautious.
+		var x Node
+		if e := v.Index(a.iter.index); e.IsValid() && !e.IsNil() {
+			x = e.Interface().(Node)
+		}
+
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, x)
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
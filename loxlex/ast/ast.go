@@ -0,0 +1,375 @@
+// Package ast declares the types used to represent the syntax trees of
+// Lox programs.
+//
+// Every node records the positions of its tokens, so its span in the
+// source code can be obtained with its Pos and End methods.
+package ast
+
+import "fmt"
+
+// Pos is a position in the source code.
+type Pos struct {
+	Offset int // Byte offset, starting at 0.
+	Line   int // Line number, starting at 1.
+	Col    int // Byte column, starting at 1.
+}
+
+// IsValid reports whether the position is valid. The zero value is
+// not.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as line:col, or "-" if it is not
+// valid.
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// add returns the position n bytes after p, which must be on the same
+// line.
+func (p Pos) add(n int) Pos {
+	return Pos{Offset: p.Offset + n, Line: p.Line, Col: p.Col + n}
+}
+
+// Node is a node of the syntax tree.
+type Node interface {
+	Pos() Pos // Position of the first byte of the node.
+	End() Pos // Position just past the last byte of the node.
+}
+
+// Expr is an expression.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Stmt is a statement or a declaration.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Ident is an identifier, such as the name of a variable or a
+// property.
+type Ident struct {
+	NamePos Pos
+	Name    string
+}
+
+func (x *Ident) Pos() Pos { return x.NamePos }
+func (x *Ident) End() Pos { return x.NamePos.add(len(x.Name)) }
+
+// Expressions.
+type (
+	// Assign is an assignment to a variable: Name = Value. Depth
+	// is that of the variable, as in [Variable].
+	Assign struct {
+		Name  *Ident
+		Value Expr
+		Depth int
+	}
+
+	// Binary is an arithmetic or comparison expression, or a comma
+	// expression: X Op Y.
+	Binary struct {
+		X     Expr
+		OpPos Pos
+		Op    string // Operator, such as "+", "<=" or ",".
+		Y     Expr
+	}
+
+	// Call is a call: Callee(Args).
+	Call struct {
+		Callee Expr
+		Lparen Pos
+		Args   []Expr
+		Rparen Pos
+	}
+
+	// Conditional is a conditional expression: Cond ? Then : Else.
+	Conditional struct {
+		Cond     Expr
+		Question Pos
+		Then     Expr
+		Colon    Pos
+		Else     Expr
+	}
+
+	// Get is a property access: X.Name.
+	Get struct {
+		X    Expr
+		Name *Ident
+	}
+
+	// Grouping is a parenthesized expression: (X).
+	Grouping struct {
+		Lparen Pos
+		X      Expr
+		Rparen Pos
+	}
+
+	// Literal is a number, a string, true, false or nil.
+	Literal struct {
+		ValuePos Pos
+		ValueEnd Pos
+		Value    any    // float64, string, bool or nil.
+		Raw      string // Text of the literal in the source code.
+	}
+
+	// Logical is a short-circuiting logical expression: X and Y or
+	// X or Y.
+	Logical struct {
+		X     Expr
+		OpPos Pos
+		Op    string // "and" or "or".
+		Y     Expr
+	}
+
+	// Set is an assignment to a property: X.Name = Value.
+	Set struct {
+		X     Expr
+		Name  *Ident
+		Value Expr
+	}
+
+	// Super is a method of the superclass: super.Method. Depth is
+	// that of the scope that binds super, as in [Variable].
+	Super struct {
+		Keyword Pos
+		Method  *Ident
+		Depth   int
+	}
+
+	// This is the this keyword. Depth is that of the scope that
+	// binds this, as in [Variable].
+	This struct {
+		Keyword Pos
+		Depth   int
+	}
+
+	// Unary is a negation: Op X.
+	Unary struct {
+		OpPos Pos
+		Op    string // "!" or "-".
+		X     Expr
+	}
+
+	// Variable is a reference to a variable. Depth is the number
+	// of scopes between the reference and the declaration of the
+	// local variable it refers to, or -1 if the variable is global.
+	// The parser sets it to -1, and the resolver computes it.
+	Variable struct {
+		Name  *Ident
+		Depth int
+	}
+)
+
+// Statements and declarations.
+type (
+	// Block is a block: { Stmts }. The blocks made by desugaring for
+	// loops have no braces, and the positions of these are not
+	// valid. Their spans cover their statements, whose order may
+	// differ from the source code.
+	Block struct {
+		Lbrace Pos
+		Stmts  []Stmt
+		Rbrace Pos
+	}
+
+	// Class is a class declaration. Superclass is nil if the class
+	// has no superclass.
+	Class struct {
+		Keyword    Pos
+		Name       *Ident
+		Superclass *Variable
+		Methods    []*Fun
+		Rbrace     Pos
+	}
+
+	// Expression is an expression evaluated for its side effects.
+	// The position of the semicolon is not valid for the increment
+	// clauses of desugared for loops.
+	Expression struct {
+		X         Expr
+		Semicolon Pos
+	}
+
+	// For is a for loop. Any of Init, Cond and Incr may be nil.
+	For struct {
+		Keyword Pos
+		Init    Stmt
+		Cond    Expr
+		Incr    Expr
+		Body    Stmt
+	}
+
+	// Fun is a function declaration or a method. The position of
+	// the keyword is not valid for methods.
+	Fun struct {
+		Keyword Pos
+		Name    *Ident
+		Params  []*Ident
+		Body    *Block
+	}
+
+	// If is a conditional statement. Else is nil if there is no else
+	// clause.
+	If struct {
+		Keyword Pos
+		Cond    Expr
+		Then    Stmt
+		Else    Stmt
+	}
+
+	// Print is a print statement.
+	Print struct {
+		Keyword   Pos
+		X         Expr
+		Semicolon Pos
+	}
+
+	// Return is a return statement. Value is nil if no value is
+	// returned.
+	Return struct {
+		Keyword   Pos
+		Value     Expr
+		Semicolon Pos
+	}
+
+	// Var is a variable declaration. Init is nil if the variable is
+	// not initialized.
+	Var struct {
+		Keyword   Pos
+		Name      *Ident
+		Init      Expr
+		Semicolon Pos
+	}
+
+	// While is a while loop.
+	While struct {
+		Keyword Pos
+		Cond    Expr
+		Body    Stmt
+	}
+)
+
+func (x *Assign) Pos() Pos      { return x.Name.Pos() }
+func (x *Binary) Pos() Pos      { return x.X.Pos() }
+func (x *Call) Pos() Pos        { return x.Callee.Pos() }
+func (x *Conditional) Pos() Pos { return x.Cond.Pos() }
+func (x *Get) Pos() Pos         { return x.X.Pos() }
+func (x *Grouping) Pos() Pos    { return x.Lparen }
+func (x *Literal) Pos() Pos     { return x.ValuePos }
+func (x *Logical) Pos() Pos     { return x.X.Pos() }
+func (x *Set) Pos() Pos         { return x.X.Pos() }
+func (x *Super) Pos() Pos       { return x.Keyword }
+func (x *This) Pos() Pos        { return x.Keyword }
+func (x *Unary) Pos() Pos       { return x.OpPos }
+func (x *Variable) Pos() Pos    { return x.Name.Pos() }
+
+func (x *Assign) End() Pos      { return x.Value.End() }
+func (x *Binary) End() Pos      { return x.Y.End() }
+func (x *Call) End() Pos        { return x.Rparen.add(1) }
+func (x *Conditional) End() Pos { return x.Else.End() }
+func (x *Get) End() Pos         { return x.Name.End() }
+func (x *Grouping) End() Pos    { return x.Rparen.add(1) }
+func (x *Literal) End() Pos     { return x.ValueEnd }
+func (x *Logical) End() Pos     { return x.Y.End() }
+func (x *Set) End() Pos         { return x.Value.End() }
+func (x *Super) End() Pos       { return x.Method.End() }
+func (x *This) End() Pos        { return x.Keyword.add(len("this")) }
+func (x *Unary) End() Pos       { return x.X.End() }
+func (x *Variable) End() Pos    { return x.Name.End() }
+
+func (s *Class) Pos() Pos      { return s.Keyword }
+func (s *Expression) Pos() Pos { return s.X.Pos() }
+func (s *For) Pos() Pos        { return s.Keyword }
+func (s *If) Pos() Pos         { return s.Keyword }
+func (s *Print) Pos() Pos      { return s.Keyword }
+func (s *Return) Pos() Pos     { return s.Keyword }
+func (s *Var) Pos() Pos        { return s.Keyword }
+func (s *While) Pos() Pos      { return s.Keyword }
+
+func (s *Block) Pos() Pos {
+	if s.Lbrace.IsValid() {
+		return s.Lbrace
+	}
+	var first Pos
+	for _, s := range s.Stmts {
+		if p := s.Pos(); p.IsValid() && (!first.IsValid() || p.Offset < first.Offset) {
+			first = p
+		}
+	}
+	return first
+}
+
+func (s *Fun) Pos() Pos {
+	if s.Keyword.IsValid() {
+		return s.Keyword
+	}
+	return s.Name.Pos()
+}
+
+func (s *Class) End() Pos  { return s.Rbrace.add(1) }
+func (s *For) End() Pos    { return s.Body.End() }
+func (s *Fun) End() Pos    { return s.Body.End() }
+func (s *Print) End() Pos  { return s.Semicolon.add(1) }
+func (s *Return) End() Pos { return s.Semicolon.add(1) }
+func (s *Var) End() Pos    { return s.Semicolon.add(1) }
+func (s *While) End() Pos  { return s.Body.End() }
+
+func (s *Block) End() Pos {
+	if s.Rbrace.IsValid() {
+		return s.Rbrace.add(1)
+	}
+	var last Pos
+	for _, s := range s.Stmts {
+		if p := s.End(); p.IsValid() && p.Offset > last.Offset {
+			last = p
+		}
+	}
+	return last
+}
+
+func (s *If) End() Pos {
+	if s.Else != nil {
+		return s.Else.End()
+	}
+	return s.Then.End()
+}
+
+func (s *Expression) End() Pos {
+	if !s.Semicolon.IsValid() {
+		return s.X.End()
+	}
+	return s.Semicolon.add(1)
+}
+
+func (*Assign) exprNode()      {}
+func (*Binary) exprNode()      {}
+func (*Call) exprNode()        {}
+func (*Conditional) exprNode() {}
+func (*Get) exprNode()         {}
+func (*Grouping) exprNode()    {}
+func (*Literal) exprNode()     {}
+func (*Logical) exprNode()     {}
+func (*Set) exprNode()         {}
+func (*Super) exprNode()       {}
+func (*This) exprNode()        {}
+func (*Unary) exprNode()       {}
+func (*Variable) exprNode()    {}
+
+func (*Block) stmtNode()      {}
+func (*Class) stmtNode()      {}
+func (*Expression) stmtNode() {}
+func (*For) stmtNode()        {}
+func (*Fun) stmtNode()        {}
+func (*If) stmtNode()         {}
+func (*Print) stmtNode()      {}
+func (*Return) stmtNode()     {}
+func (*Var) stmtNode()        {}
+func (*While) stmtNode()      {}
@@ -0,0 +1,233 @@
+package ast
+
+import "fmt"
+
+// ExprVisitor is implemented by the passes over expressions that
+// handle each kind of expression in its own method, as the visitors of
+// Crafting Interpreters do. The methods return values of type R.
+type ExprVisitor[R any] interface {
+	VisitAssign(*Assign) R
+	VisitBinary(*Binary) R
+	VisitCall(*Call) R
+	VisitConditional(*Conditional) R
+	VisitGet(*Get) R
+	VisitGrouping(*Grouping) R
+	VisitLiteral(*Literal) R
+	VisitLogical(*Logical) R
+	VisitSet(*Set) R
+	VisitSuper(*Super) R
+	VisitThis(*This) R
+	VisitUnary(*Unary) R
+	VisitVariable(*Variable) R
+}
+
+// StmtVisitor is like [ExprVisitor] for statements.
+type StmtVisitor[R any] interface {
+	VisitBlock(*Block) R
+	VisitClass(*Class) R
+	VisitExpression(*Expression) R
+	VisitFor(*For) R
+	VisitFun(*Fun) R
+	VisitIf(*If) R
+	VisitPrint(*Print) R
+	VisitReturn(*Return) R
+	VisitVar(*Var) R
+	VisitWhile(*While) R
+}
+
+// VisitExpr calls the method of v for the kind of e and returns its
+// result.
+func VisitExpr[R any](v ExprVisitor[R], e Expr) R {
+	switch e := e.(type) {
+	case *Assign:
+		return v.VisitAssign(e)
+	case *Binary:
+		return v.VisitBinary(e)
+	case *Call:
+		return v.VisitCall(e)
+	case *Conditional:
+		return v.VisitConditional(e)
+	case *Get:
+		return v.VisitGet(e)
+	case *Grouping:
+		return v.VisitGrouping(e)
+	case *Literal:
+		return v.VisitLiteral(e)
+	case *Logical:
+		return v.VisitLogical(e)
+	case *Set:
+		return v.VisitSet(e)
+	case *Super:
+		return v.VisitSuper(e)
+	case *This:
+		return v.VisitThis(e)
+	case *Unary:
+		return v.VisitUnary(e)
+	case *Variable:
+		return v.VisitVariable(e)
+	}
+	panic(fmt.Sprintf("ast.VisitExpr: unexpected expression %T", e))
+}
+
+// VisitStmt calls the method of v for the kind of s and returns its
+// result.
+func VisitStmt[R any](v StmtVisitor[R], s Stmt) R {
+	switch s := s.(type) {
+	case *Block:
+		return v.VisitBlock(s)
+	case *Class:
+		return v.VisitClass(s)
+	case *Expression:
+		return v.VisitExpression(s)
+	case *For:
+		return v.VisitFor(s)
+	case *Fun:
+		return v.VisitFun(s)
+	case *If:
+		return v.VisitIf(s)
+	case *Print:
+		return v.VisitPrint(s)
+	case *Return:
+		return v.VisitReturn(s)
+	case *Var:
+		return v.VisitVar(s)
+	case *While:
+		return v.VisitWhile(s)
+	}
+	panic(fmt.Sprintf("ast.VisitStmt: unexpected statement %T", s))
+}
+
+// A Visitor's Visit method is invoked for each node encountered by
+// [Walk]. If the result visitor w is not nil, Walk visits each of the
+// children of node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree rooted at node in depth-first order,
+// including identifiers. It starts by calling v.Visit(node), and
+// missing optional children, such as the else clause of an if
+// statement, are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Ident:
+		// Nothing to do.
+
+	case *Assign:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *Binary:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *Call:
+		Walk(v, n.Callee)
+		for _, a := range n.Args {
+			Walk(v, a)
+		}
+	case *Conditional:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		Walk(v, n.Else)
+	case *Get:
+		Walk(v, n.X)
+		Walk(v, n.Name)
+	case *Grouping:
+		Walk(v, n.X)
+	case *Literal:
+		// Nothing to do.
+	case *Logical:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *Set:
+		Walk(v, n.X)
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+	case *Super:
+		Walk(v, n.Method)
+	case *This:
+		// Nothing to do.
+	case *Unary:
+		Walk(v, n.X)
+	case *Variable:
+		Walk(v, n.Name)
+
+	case *Block:
+		for _, s := range n.Stmts {
+			Walk(v, s)
+		}
+	case *Class:
+		Walk(v, n.Name)
+		if n.Superclass != nil {
+			Walk(v, n.Superclass)
+		}
+		for _, m := range n.Methods {
+			Walk(v, m)
+		}
+	case *Expression:
+		Walk(v, n.X)
+	case *For:
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		if n.Cond != nil {
+			Walk(v, n.Cond)
+		}
+		if n.Incr != nil {
+			Walk(v, n.Incr)
+		}
+		Walk(v, n.Body)
+	case *Fun:
+		Walk(v, n.Name)
+		for _, p := range n.Params {
+			Walk(v, p)
+		}
+		Walk(v, n.Body)
+	case *If:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+	case *Print:
+		Walk(v, n.X)
+	case *Return:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *Var:
+		Walk(v, n.Name)
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+	case *While:
+		Walk(v, n.Cond)
+		Walk(v, n.Body)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// inspector adapts a function to the [Visitor] interface.
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree rooted at node in depth-first
+// order. It starts by calling f(node), which must not be nil. If f
+// returns true, Inspect invokes f recursively for each of the children
+// of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
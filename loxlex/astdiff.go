@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// astDiffer compares two syntax trees and writes their differences.
+//
+// Nodes are equal if they are of the same kind, have the same
+// operator, name or literal value, and their children are equal, so
+// positions, and thus formatting and comments, are ignored. Lists of
+// children, such as the statements of a block, are aligned on their
+// longest common subsequence of equal nodes, and then the nodes between
+// them on that of the nodes of the same kind, which are compared in
+// turn. The nodes left over are reported as changed, removed or added.
+type astDiffer struct {
+	w            io.Writer
+	nameA, nameB string
+	n            int // number of differences.
+}
+
+// writeASTDiff writes to w the structural differences between the
+// syntax trees a and b of the named files, one per line, and returns
+// their number. The lines of the nodes of a start with "-" and those
+// of b with "+":
+//
+//	a.lox:2:7: - (+ 1 2)
+//	b.lox:2:7: + (- 1 2)
+func writeASTDiff(w io.Writer, nameA string, a []ast.Stmt, nameB string, b []ast.Stmt) int {
+	d := &astDiffer{w: w, nameA: nameA, nameB: nameB}
+	d.list(stmtNodes(a), stmtNodes(b))
+	return d.n
+}
+
+// node compares the nodes a and b.
+func (d *astDiffer) node(a, b ast.Node) {
+	if nodeHead(a) != nodeHead(b) {
+		d.changed(a, b)
+		return
+	}
+	d.list(nodeChildren(a), nodeChildren(b))
+}
+
+// list compares the lists of nodes a and b. The nodes between the
+// equal ones are compared by gap.
+func (d *astDiffer) list(a, b []ast.Node) {
+	i, j := 0, 0
+	for _, m := range align(a, b, equalNodes) {
+		d.gap(a[i:m[0]], b[j:m[1]])
+		i, j = m[0]+1, m[1]+1
+	}
+	d.gap(a[i:], b[j:])
+}
+
+// gap compares the lists of nodes as and bs, which have no equal
+// nodes. The nodes of the same kind are compared, and the others are
+// reported as changed in pairs, or as removed or added.
+func (d *astDiffer) gap(as, bs []ast.Node) {
+	rest := func(as, bs []ast.Node) {
+		n := min(len(as), len(bs))
+		for k := range n {
+			d.changed(as[k], bs[k])
+		}
+		for _, a := range as[n:] {
+			d.removed(a)
+		}
+		for _, b := range bs[n:] {
+			d.added(b)
+		}
+	}
+	sameHead := func(a, b ast.Node) bool { return nodeHead(a) == nodeHead(b) }
+
+	i, j := 0, 0
+	for _, m := range align(as, bs, sameHead) {
+		rest(as[i:m[0]], bs[j:m[1]])
+		d.node(as[m[0]], bs[m[1]])
+		i, j = m[0]+1, m[1]+1
+	}
+	rest(as[i:], bs[j:])
+}
+
+// align returns the pairs of indices of the nodes of a longest common
+// subsequence of a and b, where eq reports whether two nodes match.
+func align(a, b []ast.Node, eq func(a, b ast.Node) bool) [][2]int {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if eq(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case eq(a[i], b[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// changed reports that a was replaced by b.
+func (d *astDiffer) changed(a, b ast.Node) {
+	d.n++
+	d.line(d.nameA, "-", a)
+	d.line(d.nameB, "+", b)
+}
+
+// removed reports that a is missing in b.
+func (d *astDiffer) removed(a ast.Node) {
+	d.n++
+	d.line(d.nameA, "-", a)
+}
+
+// added reports that b is missing in a.
+func (d *astDiffer) added(b ast.Node) {
+	d.n++
+	d.line(d.nameB, "+", b)
+}
+
+// line writes the line of the node n of the named file.
+func (d *astDiffer) line(name, mark string, n ast.Node) {
+	const maxLen = 60
+
+	var desc string
+	switch n := n.(type) {
+	case *ast.Ident:
+		desc = n.Name
+	default:
+		desc = sexpr(n)
+	}
+	if len(desc) > maxLen {
+		// Cut on a rune boundary, so non-ASCII names stay valid.
+		i := maxLen - 3
+		for i > 0 && !utf8.RuneStart(desc[i]) {
+			i--
+		}
+		desc = desc[:i] + "..."
+	}
+	fmt.Fprintf(d.w, "%s:%v: %s %s\n", name, n.Pos(), mark, desc)
+}
+
+// equalNodes reports whether a and b are structurally equal.
+func equalNodes(a, b ast.Node) bool {
+	if nodeHead(a) != nodeHead(b) {
+		return false
+	}
+	ca, cb := nodeChildren(a), nodeChildren(b)
+	if len(ca) != len(cb) {
+		return false
+	}
+	for i := range ca {
+		if !equalNodes(ca[i], cb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeHead returns the description of n without its children: its
+// kind and its operator, name or literal value, if any.
+func nodeHead(n ast.Node) string {
+	kind := reflect.TypeOf(n).Elem().Name()
+	switch n := n.(type) {
+	case *ast.Ident:
+		return kind + " " + n.Name
+	case *ast.Binary:
+		return kind + " " + n.Op
+	case *ast.Literal:
+		// Literals are compared by value, so that 1_000 and 1000
+		// are equal.
+		return fmt.Sprintf("%s %T %s", kind, n.Value, literalString(n.Value))
+	case *ast.Logical:
+		return kind + " " + n.Op
+	case *ast.Unary:
+		return kind + " " + n.Op
+	}
+	return kind
+}
+
+// nodeChildren returns the children of n, including identifiers, in
+// the order of [ast.Walk].
+func nodeChildren(n ast.Node) []ast.Node {
+	var children []ast.Node
+	ast.Inspect(n, func(c ast.Node) bool {
+		if c == n {
+			return true
+		}
+		if c != nil {
+			children = append(children, c)
+		}
+		return false
+	})
+	return children
+}
+
+// stmtNodes converts a slice of statements to a slice of nodes.
+func stmtNodes(stmts []ast.Stmt) []ast.Node {
+	nodes := make([]ast.Node, len(stmts))
+	for i, s := range stmts {
+		nodes[i] = s
+	}
+	return nodes
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteASTDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"print 1 + 2;", "// Comment.\nprint   1+2 ;", ""},
+		{"print 1000;", "print 1_000;", ""},
+		{"for (;;) print 1;", "while (true) print 1;", ""},
+		{"print 1 + 2;", "print 1 - 2;", "a:1:7: - (+ 1 2)\nb:1:7: + (- 1 2)\n"},
+		{"print a;\nprint b;", "print a;\nprint c;\nprint b;", "b:2:1: + (print c)\n"},
+		{"print a;\nprint b;", "print b;", "a:1:1: - (print a)\n"},
+		{"var a;", "var a = 1;", "b:1:9: + 1\n"},
+		{"fun f(a, b) { return a; }", "fun f(a, b) { return b; }", "a:1:22: - a\nb:1:22: + b\n"},
+		{
+			"if (a) { print 1; print 2; }",
+			"if (a) { print 1; print 3; } else print 4;",
+			"a:1:25: - 2\nb:1:25: + 3\nb:1:35: + (print 4)\n",
+		},
+		{
+			"print a;",
+			"print " + strings.Repeat("é", 40) + ";",
+			"a:1:7: - a\nb:1:7: + " + strings.Repeat("é", 28) + "...\n",
+		},
+	}
+	for _, tt := range tests {
+		a, diags := parse(tt.a)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.a, diags)
+		}
+		b, diags := parse(tt.b)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.b, diags)
+		}
+		var sb strings.Builder
+		n := writeASTDiff(&sb, "a", a, "b", b)
+		if got := sb.String(); got != tt.want {
+			t.Errorf("%q, %q: got:\n%s\nwant:\n%s", tt.a, tt.b, got, tt.want)
+		}
+		if (n == 0) != (tt.want == "") {
+			t.Errorf("%q, %q: got %d differences", tt.a, tt.b, n)
+		}
+	}
+}
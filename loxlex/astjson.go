@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// jsonFile is the JSON representation of the syntax tree of a file.
+type jsonFile struct {
+	File  string      `json:"file"`
+	Stmts []*jsonNode `json:"stmts"`
+}
+
+// jsonNode is the JSON representation of a node of the syntax tree.
+// Kind is the name of the type of the node in package ast, and the
+// children are named after its fields. Only the fields used by the
+// kind are present.
+type jsonNode struct {
+	Kind string    `json:"kind"`
+	Span *jsonSpan `json:"span,omitempty"` // missing for nodes made by the parser.
+
+	Name       string   `json:"name,omitempty"`
+	Op         string   `json:"op,omitempty"`
+	Literal    any      `json:"literal,omitempty"` // missing for nil.
+	Raw        string   `json:"raw,omitempty"`
+	Superclass string   `json:"superclass,omitempty"`
+	Params     []string `json:"params,omitempty"`
+	Depth      *int     `json:"depth,omitempty"` // missing for global variables.
+
+	X       *jsonNode   `json:"x,omitempty"`
+	Y       *jsonNode   `json:"y,omitempty"`
+	Callee  *jsonNode   `json:"callee,omitempty"`
+	Args    []*jsonNode `json:"args,omitempty"`
+	Value   *jsonNode   `json:"value,omitempty"`
+	Init    *jsonNode   `json:"init,omitempty"`
+	Cond    *jsonNode   `json:"cond,omitempty"`
+	Incr    *jsonNode   `json:"incr,omitempty"`
+	Then    *jsonNode   `json:"then,omitempty"`
+	Else    *jsonNode   `json:"else,omitempty"`
+	Body    *jsonNode   `json:"body,omitempty"`
+	Stmts   []*jsonNode `json:"stmts,omitempty"`
+	Methods []*jsonNode `json:"methods,omitempty"`
+}
+
+// jsonSpan is the JSON representation of the span of a node.
+type jsonSpan struct {
+	Start jsonPos `json:"start"`
+	End   jsonPos `json:"end"`
+}
+
+// jsonPos is the JSON representation of a position.
+type jsonPos struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+}
+
+// writeASTJSON writes the syntax tree of the named file to w as an
+// indented JSON object.
+func writeASTJSON(w io.Writer, name string, stmts []ast.Stmt) error {
+	f := jsonFile{File: name, Stmts: newJSONNodes(stmts)}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// newJSONNode returns the JSON representation of n, or nil if n is
+// nil.
+func newJSONNode(n ast.Node) *jsonNode {
+	if n == nil {
+		return nil
+	}
+	jn := &jsonNode{}
+	if pos, end := n.Pos(), n.End(); pos.IsValid() {
+		jn.Span = &jsonSpan{
+			Start: jsonPos{Offset: pos.Offset, Line: pos.Line, Col: pos.Col},
+			End:   jsonPos{Offset: end.Offset, Line: end.Line, Col: end.Col},
+		}
+	}
+	switch n := n.(type) {
+	case *ast.Assign:
+		jn.Kind = "Assign"
+		jn.Name = n.Name.Name
+		jn.Depth = jsonDepth(n.Depth)
+		jn.Value = newJSONNode(n.Value)
+	case *ast.Binary:
+		jn.Kind = "Binary"
+		jn.Op = n.Op
+		jn.X = newJSONNode(n.X)
+		jn.Y = newJSONNode(n.Y)
+	case *ast.Call:
+		jn.Kind = "Call"
+		jn.Callee = newJSONNode(n.Callee)
+		for _, a := range n.Args {
+			jn.Args = append(jn.Args, newJSONNode(a))
+		}
+	case *ast.Conditional:
+		jn.Kind = "Conditional"
+		jn.Cond = newJSONNode(n.Cond)
+		jn.Then = newJSONNode(n.Then)
+		jn.Else = newJSONNode(n.Else)
+	case *ast.Get:
+		jn.Kind = "Get"
+		jn.Name = n.Name.Name
+		jn.X = newJSONNode(n.X)
+	case *ast.Grouping:
+		jn.Kind = "Grouping"
+		jn.X = newJSONNode(n.X)
+	case *ast.Literal:
+		jn.Kind = "Literal"
+		jn.Literal = n.Value
+		jn.Raw = n.Raw
+	case *ast.Logical:
+		jn.Kind = "Logical"
+		jn.Op = n.Op
+		jn.X = newJSONNode(n.X)
+		jn.Y = newJSONNode(n.Y)
+	case *ast.Set:
+		jn.Kind = "Set"
+		jn.Name = n.Name.Name
+		jn.X = newJSONNode(n.X)
+		jn.Value = newJSONNode(n.Value)
+	case *ast.Super:
+		jn.Kind = "Super"
+		jn.Name = n.Method.Name
+		jn.Depth = jsonDepth(n.Depth)
+	case *ast.This:
+		jn.Kind = "This"
+		jn.Depth = jsonDepth(n.Depth)
+	case *ast.Unary:
+		jn.Kind = "Unary"
+		jn.Op = n.Op
+		jn.X = newJSONNode(n.X)
+	case *ast.Variable:
+		jn.Kind = "Variable"
+		jn.Name = n.Name.Name
+		jn.Depth = jsonDepth(n.Depth)
+
+	case *ast.Block:
+		jn.Kind = "Block"
+		jn.Stmts = newJSONNodes(n.Stmts)
+	case *ast.Class:
+		jn.Kind = "Class"
+		jn.Name = n.Name.Name
+		if n.Superclass != nil {
+			jn.Superclass = n.Superclass.Name.Name
+		}
+		for _, m := range n.Methods {
+			jn.Methods = append(jn.Methods, newJSONNode(m))
+		}
+	case *ast.Expression:
+		jn.Kind = "Expression"
+		jn.X = newJSONNode(n.X)
+	case *ast.For:
+		jn.Kind = "For"
+		jn.Init = newJSONNode(n.Init)
+		jn.Cond = newJSONNode(n.Cond)
+		jn.Incr = newJSONNode(n.Incr)
+		jn.Body = newJSONNode(n.Body)
+	case *ast.Fun:
+		jn.Kind = "Fun"
+		jn.Name = n.Name.Name
+		jn.Params = make([]string, len(n.Params))
+		for i, p := range n.Params {
+			jn.Params[i] = p.Name
+		}
+		jn.Body = newJSONNode(n.Body)
+	case *ast.If:
+		jn.Kind = "If"
+		jn.Cond = newJSONNode(n.Cond)
+		jn.Then = newJSONNode(n.Then)
+		jn.Else = newJSONNode(n.Else)
+	case *ast.Print:
+		jn.Kind = "Print"
+		jn.X = newJSONNode(n.X)
+	case *ast.Return:
+		jn.Kind = "Return"
+		jn.Value = newJSONNode(n.Value)
+	case *ast.Var:
+		jn.Kind = "Var"
+		jn.Name = n.Name.Name
+		jn.Init = newJSONNode(n.Init)
+	case *ast.While:
+		jn.Kind = "While"
+		jn.Cond = newJSONNode(n.Cond)
+		jn.Body = newJSONNode(n.Body)
+	default:
+		panic(fmt.Sprintf("unexpected node %T", n))
+	}
+	return jn
+}
+
+// newJSONNodes returns the JSON representation of stmts.
+func newJSONNodes(stmts []ast.Stmt) []*jsonNode {
+	nodes := make([]*jsonNode, len(stmts))
+	for i, s := range stmts {
+		nodes[i] = newJSONNode(s)
+	}
+	return nodes
+}
+
+// jsonDepth returns the JSON representation of the depth of a
+// variable, or nil if the variable is global.
+func jsonDepth(depth int) *int {
+	if depth < 0 {
+		return nil
+	}
+	return &depth
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// benchArgs lexes the files denoted by the command line arguments
+// repeatedly, as specified by the -benchtime flag, and prints the
+// throughput of the lexer for each one. It returns the exit status of
+// the command.
+func benchArgs(args []string) int {
+	runs, d, err := parseBenchTime(*benchTime)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	names, _, err := expandArgs(args, *recursive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	status := 0
+	for _, name := range names {
+		// Read the whole file first, so only the lexer is measured.
+		text, err := readFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			status = 1
+			continue
+		}
+		var (
+			n      int // number of runs.
+			ntoks  int // number of tokens per run.
+			start  = time.Now()
+			failed bool
+		)
+		for runs > 0 && n < runs || runs == 0 && (n == 0 || time.Since(start) < d) {
+			ntoks = 0
+			for it := range Lex(text, lexerOptions()...) {
+				if it.typ == itemError {
+					failed = true
+				}
+				ntoks++
+			}
+			n++
+		}
+		elapsed := time.Since(start).Seconds()
+		if failed {
+			fmt.Fprintf(os.Stderr, "%s: the input has lexical errors\n", displayName(name))
+			status = 1
+		}
+		fmt.Printf("%s\t%d runs\t%.2f MB/s\t%.0f tokens/s\n", displayName(name), n,
+			float64(n*len(text))/elapsed/1e6, float64(n*ntoks)/elapsed)
+	}
+	return status
+}
+
+// parseBenchTime parses the value of the -benchtime flag, which is
+// either a duration, such as 1s, or a number of runs, such as 100x.
+// It returns the number of runs, or 0 and the duration.
+func parseBenchTime(s string) (runs int, d time.Duration, err error) {
+	if n, ok := strings.CutSuffix(s, "x"); ok {
+		runs, err = strconv.Atoi(n)
+		if err != nil || runs <= 0 {
+			return 0, 0, fmt.Errorf("invalid -benchtime value %q", s)
+		}
+		return runs, 0, nil
+	}
+	d, err = time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, 0, fmt.Errorf("invalid -benchtime value %q", s)
+	}
+	return 0, d, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// binaryMagic starts every binary token stream. Its last byte is the
+// version of the format.
+//
+// After the magic, the stream holds one record per item. Integers are
+// varints and strings are length-prefixed. Positions are stored as
+// deltas from the previous item, which keeps records short, as most
+// tokens are a few bytes apart on the same line:
+//
+//	type     uvarint
+//	start    varint, delta from the end of the previous item
+//	end      uvarint, delta from start
+//	line     varint, delta from the line of the previous item
+//	col      uvarint
+//	value    string, empty for itemError
+//	lit      string
+//	base     uvarint
+//	code     string, diagnostic code for itemError, otherwise empty
+//	lead     trivia list
+//	trail    trivia list
+//
+// A trivia list is a uvarint count followed by, for every trivia, its
+// kind as a uvarint, its start as a varint delta from the start of the
+// item and its value as a string.
+const binaryMagic = "LOXT\x02"
+
+// errBadStream is returned when decoding malformed binary token
+// streams.
+var errBadStream = errors.New("malformed token stream")
+
+// binaryEncoder writes items to a binary token stream.
+type binaryEncoder struct {
+	w     *bufio.Writer
+	buf   []byte
+	end   int  // End of the previous item.
+	line  int  // Line of the previous item.
+	begun bool // Whether the magic has been written.
+}
+
+// newBinaryEncoder returns a [binaryEncoder] that writes to w. Flush
+// must be called after the last item.
+func newBinaryEncoder(w io.Writer) *binaryEncoder {
+	return &binaryEncoder{w: bufio.NewWriter(w)}
+}
+
+// Encode writes it to the stream.
+func (e *binaryEncoder) Encode(it item) error {
+	b := e.buf[:0]
+	if !e.begun {
+		b = append(b, binaryMagic...)
+		e.begun = true
+	}
+	b = binary.AppendUvarint(b, uint64(it.typ))
+	b = binary.AppendVarint(b, int64(it.start-e.end))
+	b = binary.AppendUvarint(b, uint64(it.end-it.start))
+	b = binary.AppendVarint(b, int64(it.line-e.line))
+	b = binary.AppendUvarint(b, uint64(it.col))
+	var val, code string
+	if it.typ == itemError {
+		var lerr *LexError
+		if errors.As(it.err, &lerr) {
+			code = codes[lerr.Kind]
+		}
+	} else {
+		val = it.Val()
+	}
+	b = appendString(b, val)
+	b = appendString(b, it.lit)
+	b = binary.AppendUvarint(b, uint64(it.base))
+	b = appendString(b, code)
+	b = appendTrivia(b, it.lead, it.start)
+	b = appendTrivia(b, it.trail, it.start)
+	e.buf = b
+	e.end, e.line = it.end, it.line
+	_, err := e.w.Write(b)
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *binaryEncoder) Flush() error {
+	if !e.begun {
+		e.begun = true
+		if _, err := e.w.WriteString(binaryMagic); err != nil {
+			return err
+		}
+	}
+	return e.w.Flush()
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendTrivia(b []byte, ts []trivia, start int) []byte {
+	b = binary.AppendUvarint(b, uint64(len(ts)))
+	for _, t := range ts {
+		b = binary.AppendUvarint(b, uint64(t.kind))
+		b = binary.AppendVarint(b, int64(t.start-start))
+		b = appendString(b, t.val)
+	}
+	return b
+}
+
+// binaryDecoder reads items from a binary token stream.
+type binaryDecoder struct {
+	r     *bufio.Reader
+	end   int  // End of the previous item.
+	line  int  // Line of the previous item.
+	begun bool // Whether the magic has been read.
+	err   error
+}
+
+// newBinaryDecoder returns a [binaryDecoder] that reads from r.
+func newBinaryDecoder(r io.Reader) *binaryDecoder {
+	return &binaryDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the next item of the stream. It returns [io.EOF]
+// when there are no more items.
+func (d *binaryDecoder) Decode() (item, error) {
+	if !d.begun {
+		magic := make([]byte, len(binaryMagic))
+		if _, err := io.ReadFull(d.r, magic); err != nil {
+			return item{}, d.fail(err)
+		}
+		if string(magic) != binaryMagic {
+			return item{}, fmt.Errorf("%w: bad magic %q", errBadStream, magic)
+		}
+		d.begun = true
+	}
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return item{}, io.EOF
+	}
+
+	var it item
+	it.typ = itemType(d.uvarint())
+	it.start = d.end + int(d.varint())
+	it.end = it.start + int(d.uvarint())
+	it.line = d.line + int(d.varint())
+	it.col = int(d.uvarint())
+	val := d.string()
+	it.lit = d.string()
+	it.base = int(d.uvarint())
+	code := d.string()
+	it.lead = d.trivia(it.start)
+	it.trail = d.trivia(it.start)
+	if d.err != nil {
+		return item{}, d.fail(d.err)
+	}
+
+	if it.typ == itemError {
+		it.err = &LexError{
+			Kind: errorKind(code),
+			Pos:  Position{Offset: it.start, Line: it.line, Col: it.col},
+			Err:  errors.New(it.lit),
+		}
+	} else {
+		if len(val) != it.end-it.start {
+			return item{}, fmt.Errorf("%w: value does not match its span", errBadStream)
+		}
+		it.src = &source{text: val, off: it.start}
+	}
+	d.end, d.line = it.end, it.line
+	return it, nil
+}
+
+// fail converts unexpected ends of the stream into errors.
+func (d *binaryDecoder) fail(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of stream", errBadStream)
+	}
+	return err
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	d.err = err
+	return v
+}
+
+func (d *binaryDecoder) string() string {
+	n := d.uvarint()
+	if d.err != nil || n == 0 {
+		return ""
+	}
+	// Copy instead of allocating n bytes upfront, so corrupt lengths
+	// do not cause huge allocations.
+	var sb strings.Builder
+	if _, err := io.CopyN(&sb, d.r, int64(n)); err != nil {
+		d.err = err
+	}
+	return sb.String()
+}
+
+func (d *binaryDecoder) trivia(start int) []trivia {
+	n := d.uvarint()
+	var ts []trivia
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		t := trivia{kind: triviaKind(d.uvarint())}
+		t.start = start + int(d.varint())
+		t.val = d.string()
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// errorKind returns the error kind corresponding to the diagnostic
+// code, or nil if the code is unknown.
+func errorKind(code string) error {
+	for kind, c := range codes {
+		if c == code {
+			return kind
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// encodeBinary encodes items and returns the stream and the offsets
+// in it at which every record ends.
+func encodeBinary(t *testing.T, items []item) ([]byte, []int) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := newBinaryEncoder(&buf)
+	ends := []int{len(binaryMagic)}
+	for _, it := range items {
+		if err := enc.Encode(it); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, buf.Len())
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), ends
+}
+
+// decodeBinary decodes the items of stream until the end of the
+// stream or the first error.
+func decodeBinary(stream []byte) ([]item, error) {
+	dec := newBinaryDecoder(bytes.NewReader(stream))
+	var items []item
+	for {
+		it, err := dec.Decode()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		items = append(items, it)
+	}
+}
+
+// binaryInputs are lexed to produce the token streams of the tests.
+var binaryInputs = append(slices.Clone(fuzzSeeds),
+	"var a = 1; // one\n\n/// doc\nprint a # \"x\\q\";\n",
+	"\"a${b + \"c${d}\"}e\" `raw` \"${",
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	opts := []option{withTrivia(), withRecovery(), withInterpolation(), withDocComments()}
+	for _, input := range binaryInputs {
+		items := slices.Collect(Lex(input, opts...))
+		stream, _ := encodeBinary(t, items)
+		got, err := decodeBinary(stream)
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if want := fullDescs(items); !slices.Equal(fullDescs(got), want) {
+			t.Errorf("%q: got items\n%q\nwant\n%q", input, fullDescs(got), want)
+		}
+		for i := range min(len(got), len(items)) {
+			if items[i].typ != itemError {
+				continue
+			}
+			if g, w := got[i].err.Error(), items[i].err.Error(); g != w {
+				t.Errorf("%q: item %d: got error %q, want %q", input, i, g, w)
+			}
+		}
+	}
+}
+
+func TestBinaryEmpty(t *testing.T) {
+	stream, _ := encodeBinary(t, nil)
+	if string(stream) != binaryMagic {
+		t.Fatalf("got stream %q, want %q", stream, binaryMagic)
+	}
+	items, err := decodeBinary(stream)
+	if err != nil || len(items) != 0 {
+		t.Errorf("got %d items and error %v, want none", len(items), err)
+	}
+}
+
+// TestBinaryTruncated checks that streams truncated at every length
+// decode the complete records before the cut and fail on the partial
+// one.
+func TestBinaryTruncated(t *testing.T) {
+	items := slices.Collect(Lex(binaryInputs[len(binaryInputs)-2], withTrivia(), withRecovery()))
+	stream, ends := encodeBinary(t, items)
+	for n := range len(stream) {
+		got, err := decodeBinary(stream[:n])
+		if i := slices.Index(ends, n); i >= 0 {
+			if err != nil || len(got) != i {
+				t.Errorf("cut at %d: got %d items and error %v, want %d items", n, len(got), err, i)
+			}
+			continue
+		}
+		if !errors.Is(err, errBadStream) {
+			t.Errorf("cut at %d: got error %v, want %v", n, err, errBadStream)
+		}
+	}
+}
+
+// TestBinaryCorrupt checks that corrupt streams are rejected without
+// panicking.
+func TestBinaryCorrupt(t *testing.T) {
+	items := slices.Collect(Lex(binaryInputs[len(binaryInputs)-2], withTrivia(), withRecovery()))
+	stream, _ := encodeBinary(t, items)
+
+	ident := binaryMagic + string(byte(itemIdentifier))
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"BadMagic", "LOXT\x01"},
+		{"ShortMagic", "LOX"},
+		// Identifier spanning 3 bytes with the value "ab".
+		{"ValueSpan", ident + "\x00\x03\x00\x01\x02ab\x00\x00\x00\x00\x00"},
+		// String longer than the stream.
+		{"StringLength", ident + "\x00\x02\x00\x01\xff\x01ab"},
+		{"VarintOverflow", binaryMagic + "\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeBinary([]byte(tt.stream)); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+
+	for i := range stream {
+		for _, mask := range []byte{0x01, 0x80, 0xff} {
+			corrupt := slices.Clone(stream)
+			corrupt[i] ^= mask
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("byte %d ^ %#x: panic: %v", i, mask, r)
+					}
+				}()
+				got, err := decodeBinary(corrupt)
+				if err == nil {
+					// The value of every item must still match its span.
+					for _, it := range got {
+						_ = it.Val()
+					}
+				}
+			}()
+		}
+	}
+}
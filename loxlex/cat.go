@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// catMain implements the cat subcommand, which prints Lox files
+// highlighted by the lexer, with line numbers and the lexical errors
+// underlined.
+func catMain(args []string) int {
+	fs := flag.NewFlagSet("loxlex cat", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: loxlex cat [flags] [file ...]\n")
+		fs.PrintDefaults()
+	}
+	color := fs.String("color", "auto", "colorize the output `when`: auto, always or never")
+	number := fs.Bool("n", true, "number the lines")
+	tabWidth := fs.Int("tabwidth", defaultTabWidth, "expand tabs to tab stops every `n` columns")
+	fs.Parse(args)
+
+	useColor, err := useColor(*color)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	if *tabWidth <= 0 {
+		*tabWidth = defaultTabWidth
+	}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	status := 0
+	for _, name := range names {
+		text, err := readFile(name)
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			status = 1
+			continue
+		}
+		if len(names) > 1 {
+			fmt.Fprintf(out, "==> %s <==\n", displayName(name))
+		}
+		cw := &catWriter{w: out, color: useColor, number: *number, tabWidth: *tabWidth}
+		if cw.print(text) {
+			status = 1
+		}
+	}
+	return status
+}
+
+// readFile returns the contents of the named file, or of the standard
+// input if name is "-", or of the file fetched from name if it is a
+// URL.
+func readFile(name string) (string, error) {
+	if name == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		return string(b), err
+	}
+	if isURL(name) {
+		body, err := fetch(name)
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		b, err := io.ReadAll(body)
+		return string(b), err
+	}
+	b, err := os.ReadFile(name)
+	return string(b), err
+}
+
+// catWriter prints highlighted source code.
+type catWriter struct {
+	w        *bufio.Writer
+	color    bool // whether to use ANSI colors.
+	number   bool // whether to number the lines.
+	tabWidth int
+
+	line  int          // current line.
+	fresh bool         // whether nothing has been printed on the current line.
+	vcol  int          // visual column in the current line, starting at 0.
+	diags []Diagnostic // errors not underlined yet.
+	ink   string       // color of the text being printed.
+}
+
+// print prints text and reports whether it has lexical errors.
+func (cw *catWriter) print(text string) bool {
+	lx := NewLexer(text, withRecovery(), withComments(), withTabWidth(cw.tabWidth), withMessages(messages))
+	var items []item
+	for it := lx.Next(); it.typ != itemEOF; it = lx.Next() {
+		items = append(items, it)
+	}
+	cw.diags = slices.Clone(lx.Diagnostics())
+	slices.SortStableFunc(cw.diags, func(a, b Diagnostic) int {
+		return cmp.Compare(a.Pos.Offset, b.Pos.Offset)
+	})
+	hasErrors := len(cw.diags) > 0
+
+	// Errors are emitted before the items containing them, such as
+	// an invalid escape sequence within a string.
+	slices.SortStableFunc(items, func(a, b item) int {
+		return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(b.end, a.end))
+	})
+
+	cw.line, cw.fresh = 1, true
+	pos := 0
+	for _, it := range items {
+		if it.start < pos || it.typ == itemError {
+			// Errors are underlined instead.
+			continue
+		}
+		cw.write(text[pos:it.start], "")
+		ink := ""
+		if cw.color {
+			ink = itemColor(it.typ)
+		}
+		cw.write(text[it.start:it.end], ink)
+		pos = it.end
+	}
+	cw.write(text[pos:], "")
+	if !cw.fresh || len(cw.diags) > 0 {
+		cw.endLine()
+	}
+	return hasErrors
+}
+
+// write prints s in the given color, numbering the lines and
+// expanding tabs.
+func (cw *catWriter) write(s, ink string) {
+	for i, r := range s {
+		if cw.fresh {
+			cw.lineNumber()
+		}
+		cw.setInk(ink)
+		switch {
+		case r == '\n' || r == '\r' && !strings.HasPrefix(s[i+1:], "\n"):
+			cw.setInk("")
+			cw.endLine()
+			cw.line++
+		case r == '\r':
+			// Part of "\r\n".
+		case r == '\t':
+			n := cw.tabWidth - cw.vcol%cw.tabWidth
+			cw.w.WriteString(strings.Repeat(" ", n))
+			cw.vcol += n
+		default:
+			cw.w.WriteRune(r)
+			cw.vcol++
+		}
+	}
+	cw.setInk("")
+}
+
+// setInk changes the color of the text.
+func (cw *catWriter) setInk(ink string) {
+	if ink == cw.ink {
+		return
+	}
+	if cw.ink != "" {
+		cw.w.WriteString(colorReset)
+	}
+	cw.w.WriteString(ink)
+	cw.ink = ink
+}
+
+// gutter is the width of the line numbers.
+const gutter = 5
+
+// lineNumber starts the current line, printing its number if
+// enabled.
+func (cw *catWriter) lineNumber() {
+	cw.vcol, cw.fresh = 0, false
+	if !cw.number {
+		return
+	}
+	num := fmt.Sprintf("%*d │ ", gutter, cw.line)
+	if cw.color {
+		num = colorGray + num + colorReset
+	}
+	cw.w.WriteString(num)
+}
+
+// endLine terminates the current line and underlines the errors that
+// start on it.
+func (cw *catWriter) endLine() {
+	if cw.fresh {
+		cw.lineNumber()
+	}
+	cw.w.WriteString("\n")
+	cw.fresh = true
+	for len(cw.diags) > 0 && cw.diags[0].Pos.Line <= cw.line {
+		d := cw.diags[0]
+		cw.diags = cw.diags[1:]
+
+		width := 1
+		if d.End.Line == d.Pos.Line && d.End.VCol > d.Pos.VCol {
+			width = d.End.VCol - d.Pos.VCol
+		}
+		mark := strings.Repeat(" ", d.Pos.VCol-1) + "^" + strings.Repeat("~", width-1) + " " + d.Msg
+		if cw.color {
+			mark = colorRed + mark + colorReset
+		}
+		if cw.number {
+			cw.w.WriteString(strings.Repeat(" ", gutter) + " │ ")
+		}
+		cw.w.WriteString(mark + "\n")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+// Category is a broad class of item types, such as the keywords or
+// the operators.
+type Category int
+
+// Item categories.
+const (
+	CategoryOther       Category = iota // Errors and end of file.
+	CategoryLiteral                     // Identifiers, strings and numbers.
+	CategoryKeyword                     // Reserved words.
+	CategoryOperator                    // Operators, such as "+" or "==".
+	CategoryPunctuation                 // Delimiters and separators, such as "(" or ";".
+	CategoryTrivia                      // Comments.
+)
+
+func (c Category) String() string {
+	switch c {
+	case CategoryOther:
+		return "other"
+	case CategoryLiteral:
+		return "literal"
+	case CategoryKeyword:
+		return "keyword"
+	case CategoryOperator:
+		return "operator"
+	case CategoryPunctuation:
+		return "punctuation"
+	case CategoryTrivia:
+		return "trivia"
+	}
+	return "unknown"
+}
+
+// Category returns the category of the item type.
+func (t itemType) Category() Category {
+	switch t {
+	case itemLeftParen, itemRightParen, itemLeftBrace, itemRightBrace,
+		itemComma, itemDot, itemSemicolon, itemInterpStart, itemInterpEnd:
+		return CategoryPunctuation
+	case itemMinus, itemPlus, itemSlash, itemStar, itemQuestion, itemColon,
+		itemBang, itemBangEqual, itemEqual, itemEqualEqual, itemGreater,
+		itemGreaterEqual, itemLess, itemLessEqual:
+		return CategoryOperator
+	case itemIdentifier, itemString, itemRawString, itemNumber, itemStringPart:
+		return CategoryLiteral
+	case itemComment, itemDocComment:
+		return CategoryTrivia
+	}
+	if itemAnd <= t && t <= itemKeyword {
+		return CategoryKeyword
+	}
+	return CategoryOther
+}
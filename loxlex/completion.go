@@ -0,0 +1,214 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// subcommands are the names of the subcommands of loxlex.
+var subcommands = []string{"cat", "completion", "diff", "explain", "explore", "parse"}
+
+// shells are the shells supported by the completion subcommand.
+var shells = []string{"bash", "fish", "zsh"}
+
+// completionMain implements the completion subcommand, which prints a
+// completion script for the given shell.
+func completionMain(args []string) int {
+	fs := flag.NewFlagSet("loxlex completion", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: loxlex completion bash|fish|zsh\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	flags := completionFlags()
+	switch fs.Arg(0) {
+	case "bash":
+		writeBashCompletion(os.Stdout, flags)
+	case "fish":
+		writeFishCompletion(os.Stdout, flags)
+	case "zsh":
+		writeZshCompletion(os.Stdout, flags)
+	default:
+		fmt.Fprintf(os.Stderr, "error: unsupported shell %q\n", fs.Arg(0))
+		return 2
+	}
+	return 0
+}
+
+// completionFlag describes a command line flag for completion
+// scripts.
+type completionFlag struct {
+	name   string
+	usage  string
+	arg    string   // name of the argument, or empty for boolean flags.
+	values []string // values of the argument, if they are known.
+	list   bool     // whether the argument is a comma-separated list of values.
+	file   bool     // whether the argument is a file name.
+}
+
+// completionFlags returns the flags of the command line.
+func completionFlags() []completionFlag {
+	var types []string
+	for t := itemError; t <= itemEOF; t++ {
+		types = append(types, t.String())
+	}
+	langs := []string{"en"}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		cf := completionFlag{name: f.Name}
+		cf.arg, cf.usage = flag.UnquoteUsage(f)
+		switch f.Name {
+		case "color":
+			cf.values = []string{"auto", "always", "never"}
+		case "format":
+			cf.values = formats
+		case "lang":
+			cf.values = langs
+		case "only", "exclude":
+			cf.values, cf.list = types, true
+		case "cpuprofile", "memprofile":
+			cf.file = true
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+// explainCodes returns the diagnostic codes known by the explain
+// subcommand.
+func explainCodes() []string {
+	var list []string
+	for code := range explanations {
+		list = append(list, code)
+	}
+	slices.Sort(list)
+	return list
+}
+
+// writeBashCompletion writes a bash completion script to w.
+func writeBashCompletion(w io.Writer, flags []completionFlag) {
+	var names []string
+	fmt.Fprintf(w, "# bash completion for loxlex. Generated by \"loxlex completion bash\".\n\n")
+	fmt.Fprintf(w, "_loxlex() {\n")
+	fmt.Fprintf(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	fmt.Fprintf(w, "\tif [[ $COMP_CWORD -gt 1 ]]; then\n")
+	fmt.Fprintf(w, "\t\tcase ${COMP_WORDS[1]} in\n")
+	fmt.Fprintf(w, "\t\texplain) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(explainCodes(), " "))
+	fmt.Fprintf(w, "\t\tcompletion) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(shells, " "))
+	fmt.Fprintf(w, "\t\tcat|diff|explore|parse) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n")
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "\tcase $prev in\n")
+	for _, f := range flags {
+		names = append(names, "-"+f.name)
+		if f.arg == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\t-%s|--%s)\n", f.name, f.name)
+		switch {
+		case f.list:
+			fmt.Fprintf(w, "\t\tlocal prefix=\n")
+			fmt.Fprintf(w, "\t\t[[ $cur == *,* ]] && prefix=${cur%%,*},\n")
+			fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -P \"$prefix\" -W %q -- \"${cur##*,}\"))\n", strings.Join(f.values, " "))
+		case f.values != nil:
+			fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(f.values, " "))
+		case f.file:
+			fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		}
+		fmt.Fprintf(w, "\t\treturn\n")
+		fmt.Fprintf(w, "\t\t;;\n")
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ $cur == -* ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "\telif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\") $(compgen -f -- \"$cur\"))\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "\telse\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -o filenames -F _loxlex loxlex\n")
+}
+
+// writeZshCompletion writes a zsh completion script to w.
+func writeZshCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "#compdef loxlex\n\n")
+	fmt.Fprintf(w, "# zsh completion for loxlex. Generated by \"loxlex completion zsh\".\n\n")
+	fmt.Fprintf(w, "_loxlex() {\n")
+	fmt.Fprintf(w, "\tcase $words[2] in\n")
+	fmt.Fprintf(w, "\texplain) _arguments '*:code:(%s)'; return ;;\n", strings.Join(explainCodes(), " "))
+	fmt.Fprintf(w, "\tcompletion) _arguments '1:shell:(%s)'; return ;;\n", strings.Join(shells, " "))
+	fmt.Fprintf(w, "\tcat|diff|explore|parse) _arguments '*:file:_files'; return ;;\n")
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\t_arguments \\\n")
+	for _, f := range flags {
+		spec := fmt.Sprintf("-%s[%s]", f.name, zshEscape(f.usage))
+		switch {
+		case f.list:
+			spec += fmt.Sprintf(":%s:_sequence compadd - %s", f.arg, strings.Join(f.values, " "))
+		case f.values != nil:
+			spec += fmt.Sprintf(":%s:(%s)", f.arg, strings.Join(f.values, " "))
+		case f.file:
+			spec += fmt.Sprintf(":%s:_files", f.arg)
+		case f.arg != "":
+			spec += fmt.Sprintf(":%s: ", f.arg)
+		}
+		fmt.Fprintf(w, "\t\t%s \\\n", shellQuote(spec))
+	}
+	fmt.Fprintf(w, "\t\t%s \\\n", shellQuote(fmt.Sprintf("1: :{_alternative 'subcommands:subcommand:(%s)' 'files:file:_files'}", strings.Join(subcommands, " "))))
+	fmt.Fprintf(w, "\t\t'*:file:_files'\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "if [[ $funcstack[1] == _loxlex ]]; then\n")
+	fmt.Fprintf(w, "\t_loxlex \"$@\"\n")
+	fmt.Fprintf(w, "else\n")
+	fmt.Fprintf(w, "\tcompdef _loxlex loxlex\n")
+	fmt.Fprintf(w, "fi\n")
+}
+
+// writeFishCompletion writes a fish completion script to w.
+func writeFishCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "# fish completion for loxlex. Generated by \"loxlex completion fish\".\n\n")
+	fmt.Fprintf(w, "complete -c loxlex -n '__fish_is_first_arg' -a %s\n", shellQuote(strings.Join(subcommands, " ")))
+	fmt.Fprintf(w, "complete -c loxlex -n '__fish_seen_subcommand_from explain' -f -a %s\n", shellQuote(strings.Join(explainCodes(), " ")))
+	fmt.Fprintf(w, "complete -c loxlex -n '__fish_seen_subcommand_from completion' -f -a %s\n", shellQuote(strings.Join(shells, " ")))
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c loxlex -o %s -d %s", f.name, shellQuote(f.usage))
+		switch {
+		case f.list:
+			values := strings.Join(f.values, ",")
+			line += fmt.Sprintf(" -x -a %s", shellQuote(`(__fish_complete_list , "string split , `+values+`")`))
+		case f.values != nil:
+			line += fmt.Sprintf(" -x -a %s", shellQuote(strings.Join(f.values, " ")))
+		case f.file:
+			line += " -r -F"
+		case f.arg != "":
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// shellQuote quotes s for POSIX shells, zsh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscape escapes the characters of s that are special in the
+// descriptions of the options of _arguments.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
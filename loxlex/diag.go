@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Position describes a location in the input.
+type Position struct {
+	Offset int // Byte offset, starting at 0.
+	Line   int // Line number, starting at 1.
+	Col    int // Byte column, starting at 1.
+
+	// VCol is the visual column, starting at 1, counting runes and
+	// expanding tabs to the tab width of the lexer. Zero means it is
+	// unknown.
+	VCol int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Severity is the severity of a diagnostic.
+type Severity int
+
+// Diagnostic severities.
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "unknown"
+}
+
+// Diagnostic codes.
+const (
+	CodeUnclosedString  = "LOX0001"
+	CodeUnexpectedChar  = "LOX0002"
+	CodeUnclosedComment = "LOX0003"
+	CodeUnknownEscape   = "LOX0004"
+	CodeInvalidNumber   = "LOX0005"
+	CodeReadError       = "LOX0006"
+	CodeNonASCII        = "LOX0007"
+	CodeInvalidUTF8     = "LOX0008"
+	CodeInputTooLarge   = "LOX0009"
+	CodeTokenTooLong    = "LOX0010"
+	CodeInvalidEscape   = "LOX0011"
+	CodeNewlineInString = "LOX0012"
+	CodeBidiControl     = "LOX0013"
+	CodeConfusable      = "LOX0014"
+	CodeInvisibleChar   = "LOX0015"
+)
+
+// Lexical error kinds. The errors of error items can be compared
+// against them with [errors.Is].
+var (
+	ErrUnclosedString  = errors.New("unclosed string")
+	ErrUnexpectedChar  = errors.New("unexpected character")
+	ErrUnclosedComment = errors.New("unclosed comment")
+	ErrUnknownEscape   = errors.New("unknown escape sequence")
+	ErrInvalidNumber   = errors.New("invalid number")
+	ErrRead            = errors.New("read error")
+	ErrNonASCII        = errors.New("non-ASCII character")
+	ErrInvalidUTF8     = errors.New("invalid UTF-8 encoding")
+	ErrInputTooLarge   = errors.New("input too large")
+	ErrTokenTooLong    = errors.New("token too long")
+	ErrInvalidEscape   = errors.New("invalid escape sequence")
+	ErrNewlineInString = errors.New("newline in string")
+)
+
+// codes associates error kinds with the corresponding diagnostic
+// codes.
+var codes = map[error]string{
+	ErrUnclosedString:  CodeUnclosedString,
+	ErrUnexpectedChar:  CodeUnexpectedChar,
+	ErrUnclosedComment: CodeUnclosedComment,
+	ErrUnknownEscape:   CodeUnknownEscape,
+	ErrInvalidNumber:   CodeInvalidNumber,
+	ErrRead:            CodeReadError,
+	ErrNonASCII:        CodeNonASCII,
+	ErrInvalidUTF8:     CodeInvalidUTF8,
+	ErrInputTooLarge:   CodeInputTooLarge,
+	ErrTokenTooLong:    CodeTokenTooLong,
+	ErrInvalidEscape:   CodeInvalidEscape,
+	ErrNewlineInString: CodeNewlineInString,
+}
+
+// LexError is a lexical error.
+type LexError struct {
+	Kind error    // Kind of error, such as ErrUnclosedString.
+	Pos  Position // Position of the offending input.
+	Err  error    // Detailed error.
+}
+
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Pos, e.Err)
+}
+
+// Unwrap returns the kind of the error and the detailed error, so
+// both can be matched with [errors.Is] and [errors.As].
+func (e *LexError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Diagnostic describes a problem found in the input.
+type Diagnostic struct {
+	Pos      Position // Start of the offending input.
+	End      Position // End of the offending input.
+	Severity Severity // Severity, such as SeverityError.
+	Code     string   // Code, such as CodeUnclosedString.
+	Msg      string   // Human readable message.
+	Notes    []Note   // Related locations, if any.
+}
+
+// Note describes a location related to a diagnostic, such as the
+// opening parenthesis of an unclosed one.
+type Note struct {
+	Pos Position // Start of the related input.
+	End Position // End of the related input.
+	Msg string   // Human readable message.
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v[%s]: %s", d.Pos, d.Severity, d.Code, d.Msg)
+}
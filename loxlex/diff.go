@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// diffMain implements the diff subcommand, which compares the token
+// streams of two files, ignoring whitespace and comments, and prints
+// the first difference. With the -ast flag, it compares the syntax
+// trees of the files instead and prints all their differences. Like
+// diff, it exits with status 0 if the files are equal, 1 if they
+// differ and 2 on errors.
+func diffMain(args []string) int {
+	fs := flag.NewFlagSet("loxlex diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: loxlex diff [flags] file1 file2\n")
+		fs.PrintDefaults()
+	}
+	astDiff := fs.Bool("ast", false, "compare the syntax trees of the files instead of their tokens")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if *astDiff {
+		return diffASTs(fs.Arg(0), fs.Arg(1))
+	}
+
+	var streams [2][]item
+	for i, name := range fs.Args() {
+		text, err := readFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+		for it := range Lex(text, withMessages(messages)) {
+			if it.typ == itemError {
+				fmt.Fprintf(os.Stderr, "%s:%v\n", displayName(name), it.err)
+				return 2
+			}
+			streams[i] = append(streams[i], it)
+		}
+	}
+
+	a, b := streams[0], streams[1]
+	for i := range min(len(a), len(b)) {
+		if sameToken(a[i], b[i]) {
+			continue
+		}
+		fmt.Printf("token streams differ at token %d:\n", i+1)
+		fmt.Printf("%s:%d:%d: %v %s\n", displayName(fs.Arg(0)), a[i].line, a[i].col, a[i].typ, a[i].Val())
+		fmt.Printf("%s:%d:%d: %v %s\n", displayName(fs.Arg(1)), b[i].line, b[i].col, b[i].typ, b[i].Val())
+		return 1
+	}
+	return 0
+}
+
+// diffASTs implements diff -ast for the named files.
+func diffASTs(nameA, nameB string) int {
+	var trees [2][]ast.Stmt
+	for i, name := range []string{nameA, nameB} {
+		text, err := readFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+		stmts, diags := parse(text, withMessages(messages))
+		if len(diags) > 0 {
+			for _, d := range diags {
+				writeSnippet(os.Stderr, displayName(name), text, d)
+			}
+			return 2
+		}
+		trees[i] = stmts
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if writeASTDiff(out, displayName(nameA), trees[0], displayName(nameB), trees[1]) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// sameToken reports whether a and b are the same token. Strings and
+// numbers are compared by their literal values, so "\u{41}" and "A",
+// or 1_000 and 1000, are the same.
+func sameToken(a, b item) bool {
+	if a.typ != b.typ {
+		return false
+	}
+	switch a.typ {
+	case itemString, itemRawString, itemStringPart, itemNumber:
+		return a.lit == b.lit
+	}
+	return a.Val() == b.Val()
+}
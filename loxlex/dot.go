@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// dotWriter renders syntax trees as Graphviz graphs.
+type dotWriter struct {
+	w *bufio.Writer
+	n int // number of nodes written.
+}
+
+// writeDOT writes the syntax tree of the named file to w as a Graphviz
+// digraph. Every statement is the root of a tree, and the edges are
+// labelled with the role of the children, such as cond or body.
+func writeDOT(w io.Writer, name string, stmts []ast.Stmt) error {
+	dw := &dotWriter{w: bufio.NewWriter(w)}
+	fmt.Fprintf(dw.w, "digraph %s {\n", strconv.Quote(name))
+	fmt.Fprintf(dw.w, "\tnode [shape=box, fontname=\"monospace\"];\n")
+	fmt.Fprintf(dw.w, "\tordering=out;\n")
+	for _, s := range stmts {
+		dw.node(s)
+	}
+	fmt.Fprintf(dw.w, "}\n")
+	return dw.w.Flush()
+}
+
+// node writes n and its descendants and returns the identifier of n
+// in the graph.
+func (dw *dotWriter) node(n ast.Node) string {
+	id := fmt.Sprintf("n%d", dw.n)
+	dw.n++
+	// The node is written before its children, so that they are
+	// laid out in order.
+	write := func(format string, args ...any) {
+		label := fmt.Sprintf(format, args...)
+		fmt.Fprintf(dw.w, "\t%s [label=%s];\n", id, strconv.Quote(label))
+	}
+	edge := func(role string, c ast.Node) {
+		if c == nil {
+			return
+		}
+		cid := dw.node(c)
+		if role == "" {
+			fmt.Fprintf(dw.w, "\t%s -> %s;\n", id, cid)
+		} else {
+			fmt.Fprintf(dw.w, "\t%s -> %s [label=%s];\n", id, cid, strconv.Quote(role))
+		}
+	}
+
+	switch n := n.(type) {
+	case *ast.Assign:
+		write("Assign %s", n.Name.Name)
+		edge("", n.Value)
+	case *ast.Binary:
+		write("Binary %s", n.Op)
+		edge("", n.X)
+		edge("", n.Y)
+	case *ast.Call:
+		write("Call")
+		edge("callee", n.Callee)
+		for i, a := range n.Args {
+			edge(fmt.Sprintf("arg %d", i), a)
+		}
+	case *ast.Conditional:
+		write("Conditional")
+		edge("cond", n.Cond)
+		edge("then", n.Then)
+		edge("else", n.Else)
+	case *ast.Get:
+		write("Get %s", n.Name.Name)
+		edge("", n.X)
+	case *ast.Grouping:
+		write("Grouping")
+		edge("", n.X)
+	case *ast.Literal:
+		write("Literal %s", literalString(n.Value))
+	case *ast.Logical:
+		write("Logical %s", n.Op)
+		edge("", n.X)
+		edge("", n.Y)
+	case *ast.Set:
+		write("Set %s", n.Name.Name)
+		edge("object", n.X)
+		edge("value", n.Value)
+	case *ast.Super:
+		write("Super %s", n.Method.Name)
+	case *ast.This:
+		write("This")
+	case *ast.Unary:
+		write("Unary %s", n.Op)
+		edge("", n.X)
+	case *ast.Variable:
+		write("Variable %s", n.Name.Name)
+
+	case *ast.Block:
+		write("Block")
+		for _, s := range n.Stmts {
+			edge("", s)
+		}
+	case *ast.Class:
+		if n.Superclass != nil {
+			write("Class %s < %s", n.Name.Name, n.Superclass.Name.Name)
+		} else {
+			write("Class %s", n.Name.Name)
+		}
+		for _, m := range n.Methods {
+			edge("", m)
+		}
+	case *ast.Expression:
+		write("Expression")
+		edge("", n.X)
+	case *ast.For:
+		write("For")
+		edge("init", n.Init)
+		edge("cond", n.Cond)
+		edge("incr", n.Incr)
+		edge("body", n.Body)
+	case *ast.Fun:
+		params := make([]string, len(n.Params))
+		for i, p := range n.Params {
+			params[i] = p.Name
+		}
+		write("Fun %s(%s)", n.Name.Name, strings.Join(params, ", "))
+		for _, s := range n.Body.Stmts {
+			edge("", s)
+		}
+	case *ast.If:
+		write("If")
+		edge("cond", n.Cond)
+		edge("then", n.Then)
+		edge("else", n.Else)
+	case *ast.Print:
+		write("Print")
+		edge("", n.X)
+	case *ast.Return:
+		write("Return")
+		edge("", n.Value)
+	case *ast.Var:
+		write("Var %s", n.Name.Name)
+		edge("", n.Init)
+	case *ast.While:
+		write("While")
+		edge("cond", n.Cond)
+		edge("body", n.Body)
+	default:
+		panic(fmt.Sprintf("unexpected node %T", n))
+	}
+	return id
+}
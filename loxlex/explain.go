@@ -0,0 +1,398 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// explanations are the extended descriptions of the diagnostic codes
+// printed by the explain subcommand.
+var explanations = map[string]string{
+	CodeUnclosedString: `A string literal is not closed before the end of the input.
+
+Strings start and end with a double quote and, unlike in many other
+languages, may span several lines, so a missing closing quote makes
+the rest of the file part of the string:
+
+    print "Hello, world;
+    print "bye";
+
+The error is reported at the opening quote. Add the missing quote:
+
+    print "Hello, world";
+    print "bye";
+
+Raw strings, which are delimited by backquotes, are reported in the
+same way, as are embedded expressions such as "${x}" that are not
+closed with }.`,
+
+	CodeUnexpectedChar: `The input contains a character that cannot start any token.
+
+Lox does not use characters such as @, #, $ or ~ outside of strings
+and comments:
+
+    var total = price # 2;
+
+Characters pasted from word processors, such as smart quotes or
+non-breaking spaces, are a common source of this error, because they
+look like their ASCII counterparts:
+
+    print “hi”;
+
+Use straight double quotes and ASCII spaces. The -hexdump flag shows
+the bytes of every token and helps to find them.`,
+
+	CodeUnclosedComment: `A block comment is not closed before the end of the input.
+
+Block comments start with /* and end with the next */, so a missing
+*/ comments out the rest of the file:
+
+    /* Compute the total.
+    var total = price * 2;
+
+Close the comment, or use a line comment:
+
+    /* Compute the total. */
+    var total = price * 2;`,
+
+	CodeUnknownEscape: `A string contains a backslash followed by a character that does
+not form an escape sequence.
+
+The escape sequences are \n (newline), \t (tab), \" (double quote),
+\\ (backslash) and \u{...} (Unicode code point), plus \$ when string
+interpolation is enabled:
+
+    print "C:\lox\bin";
+
+Double the backslashes to write them literally:
+
+    print "C:\\lox\\bin";`,
+
+	CodeInvalidNumber: `A number literal is malformed.
+
+Numbers are decimal, optionally with a fractional part and an
+exponent, or hexadecimal (0x) or binary (0b) integers. Underscores
+may separate digits, but not start or end a number or follow another
+underscore:
+
+    var a = 1e+;    // the exponent has no digits.
+    var b = 0x;     // no hexadecimal digits.
+    var c = 0b102;  // 2 is not a binary digit.
+    var d = 1__000; // misplaced digit separator.
+
+Write instead:
+
+    var a = 1e3;
+    var b = 0x1F;
+    var c = 0b101;
+    var d = 1_000;`,
+
+	CodeReadError: `The input could not be read.
+
+The lexer stopped because reading the input failed, for instance
+because the file was removed or the network connection was lost
+while fetching it. The message includes the error of the system.
+Check that the input can be read and try again.`,
+
+	CodeNonASCII: `The input contains a non-ASCII character where only ASCII is
+allowed.
+
+In strict ASCII mode, characters outside of ASCII are rejected
+everywhere but in strings and comments, and, in ASCII identifiers
+mode, identifiers are made of ASCII letters, digits and underscores:
+
+    var café = 1;
+
+Use ASCII names:
+
+    var cafe = 1;`,
+
+	CodeInvalidUTF8: `The input is not valid UTF-8.
+
+Lox source files must be encoded in UTF-8. This error is usually
+caused by files saved in a legacy encoding such as Latin-1:
+
+    print "caf\xe9";
+
+Convert the file to UTF-8, for instance with iconv:
+
+    iconv -f latin1 -t utf-8 old.lox > new.lox`,
+
+	CodeInputTooLarge: `The input is larger than the maximum size accepted by the lexer.
+
+Lexers can be configured to reject inputs above a given size, to
+protect services from excessive memory use. Split the program into
+smaller files or raise the limit.`,
+
+	CodeTokenTooLong: `A token is longer than the maximum length accepted by the lexer.
+
+Lexers can be configured to reject tokens above a given length, such
+as huge string literals, to protect services from excessive memory
+use. Shorten the token, for instance by reading large texts from a
+file at run time, or raise the limit.`,
+
+	CodeInvalidEscape: `A Unicode escape sequence is malformed.
+
+Unicode escape sequences are written \u{...} with between one and six
+hexadecimal digits denoting a code point that is not a surrogate:
+
+    print "\u00e9";      // missing braces.
+    print "\u{}";        // no digits.
+    print "\u{110000}";  // out of range.
+    print "\u{d800}";    // surrogate.
+
+Write instead:
+
+    print "\u{e9}";`,
+
+	CodeNewlineInString: `A string contains a line break while single-line strings are
+required.
+
+In single-line strings mode, strings cannot span several lines, which
+catches missing closing quotes early:
+
+    print "Hello,
+    world";
+
+Use the \n escape sequence instead:
+
+    print "Hello,\nworld";`,
+
+	CodeBidiControl: `A comment or string contains a bidirectional control character.
+
+Unicode bidirectional control characters, such as U+202E (right-to-
+left override), change the order in which text is displayed. They can
+make code look different from what the lexer sees, hiding malicious
+code in what looks like a comment or a string (CVE-2021-42574, "Trojan
+Source"). Remove the character unless it is really needed, and then
+prefer writing it with a \u{...} escape sequence.
+
+This warning is only reported when security warnings are enabled.`,
+
+	CodeConfusable: `An identifier contains a character that looks like a different
+ASCII character.
+
+Characters from other scripts, such as the Cyrillic а (U+0430) or the
+Greek ο (U+03BF), look like Latin letters, so two identifiers may look
+the same while being different:
+
+    var pаssword = "secret"; // the a is Cyrillic.
+    print password;          // a different variable.
+
+Retype the identifier with the intended characters.
+
+This warning is only reported when security warnings are enabled.`,
+
+	CodeInvisibleChar: `A comment or string contains an invisible character.
+
+Characters such as U+200B (zero width space) or U+2060 (word joiner)
+are not displayed by most editors, so two strings may look the same
+while being different. For instance, a comparison with a string that
+looks like "secret" is always false if the string ends with a U+200B.
+
+Remove the character unless it is really needed, and then prefer
+writing it with a \u{...} escape sequence.
+
+This warning is only reported when security warnings are enabled.`,
+
+	CodeSyntaxError: `The tokens of the program do not follow the grammar of Lox.
+
+The parser reports the token that cannot continue the program, along
+with what it expected instead, and resumes at the next statement, so
+a single mistake may cause several errors. A common cause is a
+missing semicolon at the end of a statement:
+
+    print "one"
+    print "two";
+
+Here the parser expects ';' after "one" but finds print. The error
+is reported at the token found, which may be on the line after the
+actual mistake. Add the missing token:
+
+    print "one";
+    print "two";`,
+
+	CodeTooManyArgs: `A call has more than 255 arguments, or a function more than 255
+parameters.
+
+The limit is imposed by the bytecode of clox, which stores the number
+of arguments of a call in a single byte, and jlox enforces it too so
+that programs run the same in both interpreters. The parser keeps
+going after this error.
+
+Pass the values in a data structure, such as an instance of a class,
+instead of as separate arguments.`,
+
+	CodeTooDeep: `Statements, functions or expressions are nested too deeply.
+
+The parser is recursive, so it limits the nesting depth of the
+program to avoid running out of stack on inputs such as thousands of
+opening parentheses. The limit counts the rules of the grammar being
+parsed rather than the constructs of the source code, and every level
+of parentheses in an expression takes three levels. The parser stops
+after this error, so the rest of the program is not checked.
+
+Split the code into smaller functions, or store intermediate results
+of long expressions in variables.`,
+
+	CodeReadInInitializer: `The initializer of a local variable refers to the variable itself.
+
+A local variable is in scope from its declaration, but it is only
+defined after its initializer is evaluated, so reading it there is
+an error, even if a variable with the same name exists in an
+enclosing scope:
+
+    var a = "outer";
+    {
+      var a = a;
+    }
+
+Rename one of the variables, or initialize the local variable with
+another expression. Global variables are not checked, and the
+initializer of a global variable reads the previous value of the
+variable, if any.`,
+
+	CodeUnusedVariable: `A local variable is declared but its value is never read.
+
+Assigning a variable does not count as using it, so the variable is
+reported even if it is assigned after its declaration:
+
+    {
+      var total = 0;
+      total = 1;
+    }
+
+Global variables and parameters are not reported. Remove the
+variable, or use it where it was meant to be used.`,
+
+	CodeUnusedFunction: `A function is declared but never called or otherwise referred to.
+
+The references from the body of a function to itself, as in recursive
+calls, do not count as uses of the function. Global functions are
+reported if they are not used anywhere in the file, even before their
+declaration, so functions meant to be used by other files are
+reported too. Methods are not reported.
+
+Remove the function, or call it where it was meant to be called.`,
+
+	CodeInvalidReturn: `A return statement is not allowed where it is.
+
+Return statements are only allowed in functions and methods:
+
+    return "done";
+
+Also, the initializer of a class, the init method, always returns
+the instance, so its return statements cannot have a value:
+
+    class Point {
+      init(x) {
+        this.x = x;
+        return this;
+      }
+    }
+
+Remove the statement or its value.`,
+
+	CodeThisOutsideClass: `The keyword this is used outside of a method.
+
+this refers to the instance on which a method is called, so it can
+only be used in the methods of a class, including the functions
+declared in them.`,
+
+	CodeInvalidSuper: `The keyword super is used outside of a subclass.
+
+super refers to the methods of the superclass, so it can only be
+used in the methods of a class that has a superclass:
+
+    class A {
+      m() {
+        return super.m();
+      }
+    }
+
+Declare the superclass with class A < B, or call the method on this
+instead.`,
+
+	CodeDuplicateDecl: `A local variable is declared twice in the same scope.
+
+Redeclaring a local variable is almost always a mistake, so it is an
+error, although the same is allowed for global variables to make
+experimenting in the REPL easier:
+
+    fun f(a) {
+      var a = 1;
+    }
+
+The parameters of a function are in the same scope as the variables
+declared at the start of its body. Rename one of the variables, or
+assign the existing variable instead of declaring it again.`,
+
+	CodeSelfInheritance: `A class is declared with itself as superclass.
+
+A class cannot inherit from itself:
+
+    class A < A {}
+
+Remove the superclass or give the name of another class.`,
+
+	CodeUnreachable: `A statement can never be executed.
+
+The statements of a block that follow a statement that always
+returns are unreachable. A statement always returns if it is a
+return statement, a block that contains one, or an if statement
+whose branches both always return:
+
+    fun sign(n) {
+      if (n < 0) return -1; else return 1;
+      print "unreachable";
+    }
+
+The body of a loop whose condition is false or nil is unreachable
+too, as in while (false). Only literal conditions are detected.
+
+Remove the unreachable code, or fix the condition or the return
+statement that prevents it from running.`,
+}
+
+// explainMain implements the explain subcommand, which prints the
+// extended description of diagnostic codes or, if none is given, the
+// list of codes.
+func explainMain(args []string) int {
+	fs := flag.NewFlagSet("loxlex explain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: loxlex explain [code ...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		list := make([]string, 0, len(explanations))
+		for code := range explanations {
+			list = append(list, code)
+		}
+		slices.Sort(list)
+		for _, code := range list {
+			fmt.Printf("%s  %s\n", code, ruleDescription(code))
+		}
+		return 0
+	}
+
+	status := 0
+	for i, arg := range fs.Args() {
+		code := strings.ToUpper(arg)
+		text, ok := explanations[code]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: unknown diagnostic code %q\n", arg)
+			status = 2
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s: %s\n", code, text)
+	}
+	return status
+}
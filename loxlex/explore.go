@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Escape sequences used to control the terminal in the explore
+// subcommand.
+const (
+	termAltScreen  = "\x1b[?1049h" // switch to the alternate screen.
+	termMainScreen = "\x1b[?1049l" // switch back to the main screen.
+	termHideCursor = "\x1b[?25l"
+	termShowCursor = "\x1b[?25h"
+	termReverse    = "\x1b[7m"
+	termNoReverse  = "\x1b[27m"
+)
+
+// exploreGutter is the width of the line numbers of the source pane
+// of the explore subcommand.
+const exploreGutter = 5
+
+// exploreMain implements the explore subcommand, which shows the
+// source code of a file and its tokens side by side in the terminal.
+// The selected token is highlighted in the source code.
+func exploreMain(args []string) int {
+	fs := flag.NewFlagSet("loxlex explore", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: loxlex explore [flags] file\n")
+		fs.PrintDefaults()
+	}
+	tabWidth := fs.Int("tabwidth", defaultTabWidth, "expand tabs to tab stops every `n` columns")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *tabWidth <= 0 {
+		*tabWidth = defaultTabWidth
+	}
+
+	text, err := readFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	e := newExplorer(text, *tabWidth)
+
+	// The keys are read from the terminal, so the file can be
+	// read from the standard input.
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer tty.Close()
+	restore, err := rawMode(tty)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer restore()
+
+	e.w = bufio.NewWriter(tty)
+	e.w.WriteString(termAltScreen + termHideCursor)
+	defer func() {
+		e.w.WriteString(termShowCursor + termMainScreen)
+		e.w.Flush()
+	}()
+	e.run(tty, bufio.NewReader(tty))
+	return 0
+}
+
+// stty runs the stty command on tty and returns its output.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// rawMode puts tty in raw mode, so keys are read as soon as they are
+// pressed and are not echoed. The returned function restores the
+// previous mode.
+func rawMode(tty *os.File) (restore func(), err error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(tty, saved) }, nil
+}
+
+// termSize returns the size of tty.
+func termSize(tty *os.File) (rows, cols int, err error) {
+	out, err := stty(tty, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscan(out, &rows, &cols); err != nil {
+		return 0, 0, fmt.Errorf("stty: unexpected size %q", out)
+	}
+	return rows, cols, nil
+}
+
+// explorer is the state of the explore subcommand.
+type explorer struct {
+	w        *bufio.Writer
+	text     string
+	src      *Source // lines of text.
+	tabWidth int
+	items    []item
+	sel      int // index of the selected item.
+
+	rows, cols int // size of the terminal.
+	srcTop     int // first line shown in the source pane.
+	srcLeft    int // first visual column shown in the source pane.
+	listTop    int // first item shown in the token pane.
+}
+
+// newExplorer returns an explorer of the items of text.
+func newExplorer(text string, tabWidth int) *explorer {
+	e := &explorer{text: text, src: NewSource(""), tabWidth: tabWidth}
+	for it := range Lex(text, withRecovery(), withComments(), withMessages(messages), withSource(e.src)) {
+		e.items = append(e.items, it)
+	}
+	return e
+}
+
+// run redraws the screen after every key read from r until the user
+// quits.
+func (e *explorer) run(tty *os.File, r *bufio.Reader) {
+	for {
+		if rows, cols, err := termSize(tty); err == nil {
+			e.rows, e.cols = rows, cols
+		} else {
+			e.rows, e.cols = 24, 80
+		}
+		e.draw()
+		if err := e.w.Flush(); err != nil {
+			return
+		}
+
+		page := max(1, e.rows-2)
+		switch readKey(r) {
+		case "q", "\x03", "":
+			return
+		case "j", "n", "\x1b[B", "\x1b[C":
+			e.sel++
+		case "k", "p", "\x1b[A", "\x1b[D":
+			e.sel--
+		case " ", "\x1b[6~":
+			e.sel += page
+		case "b", "\x1b[5~":
+			e.sel -= page
+		case "g", "\x1b[H":
+			e.sel = 0
+		case "G", "\x1b[F":
+			e.sel = len(e.items) - 1
+		}
+		e.sel = max(0, min(e.sel, len(e.items)-1))
+	}
+}
+
+// readKey reads a key from r. Keys that send escape sequences, such as
+// the arrow keys, are returned as the whole sequence. It returns the
+// empty string on errors.
+func readKey(r *bufio.Reader) string {
+	c, err := r.ReadByte()
+	if err != nil {
+		return ""
+	}
+	if c != '\x1b' || r.Buffered() == 0 {
+		return string(c)
+	}
+	seq := []byte{c}
+	for r.Buffered() > 0 {
+		c, _ := r.ReadByte()
+		seq = append(seq, c)
+		// CSI sequences end with a byte in the range @ to ~.
+		if len(seq) > 2 && c >= '@' && c <= '~' {
+			break
+		}
+	}
+	return string(seq)
+}
+
+// draw draws the screen: a title line, the source pane on the left,
+// the token pane on the right and a status line.
+func (e *explorer) draw() {
+	h := max(1, e.rows-2)
+	srcWidth := e.cols / 2
+	listWidth := max(0, e.cols-srcWidth-1)
+	it := e.items[e.sel]
+
+	// Keep the selected item visible in both panes.
+	line := it.line - 1
+	if line < e.srcTop {
+		e.srcTop = line
+	} else if line >= e.srcTop+h {
+		e.srcTop = line - h + 1
+	}
+	textWidth := max(1, srcWidth-exploreGutter)
+	if vcol := e.visualCol(line, it.start); vcol < e.srcLeft || vcol >= e.srcLeft+textWidth {
+		e.srcLeft = max(0, vcol-textWidth/2)
+	}
+	if e.sel < e.listTop {
+		e.listTop = e.sel
+	} else if e.sel >= e.listTop+h {
+		e.listTop = e.sel - h + 1
+	}
+
+	e.w.WriteString("\x1b[H")
+	title := fmt.Sprintf(" token %d of %d", e.sel+1, len(e.items))
+	e.w.WriteString(termReverse + pad(title, e.cols) + termNoReverse + "\r\n")
+	for row := range h {
+		e.w.WriteString(e.sourceRow(e.srcTop+row, srcWidth, textWidth, it))
+		e.w.WriteString("│")
+		e.w.WriteString(e.listRow(e.listTop+row, listWidth))
+		e.w.WriteString("\r\n")
+	}
+	e.w.WriteString(pad(e.status(it), e.cols))
+}
+
+// status returns the status line describing it.
+func (e *explorer) status(it item) string {
+	end := e.src.Pos(it.end)
+	s := fmt.Sprintf(" %v %d:%d-%d:%d", it.typ, it.line, it.col, end.Line, end.Col)
+	if it.err != nil {
+		s += ": " + it.err.Error()
+	}
+	return s + "  (j/k: next/previous, g/G: first/last, q: quit)"
+}
+
+// lineText returns the text of the given line, starting at 0, without
+// the line terminator, and its offset.
+func (e *explorer) lineText(line int) (string, int) {
+	start := e.src.LineStart(line + 1)
+	end := len(e.text)
+	if line+1 < e.src.LineCount() {
+		end = e.src.LineStart(line + 2)
+	}
+	s := strings.TrimSuffix(e.text[start:end], "\n")
+	return strings.TrimSuffix(s, "\r"), start
+}
+
+// visualCol returns the visual column, starting at 0, of the given
+// offset of the given line.
+func (e *explorer) visualCol(line, off int) int {
+	if line < 0 || line >= e.src.LineCount() {
+		return 0
+	}
+	s, start := e.lineText(line)
+	vcol := 0
+	for i, r := range s {
+		if start+i >= off {
+			break
+		}
+		if r == '\t' {
+			vcol += e.tabWidth - vcol%e.tabWidth
+		} else {
+			vcol++
+		}
+	}
+	return vcol
+}
+
+// sourceRow returns the given line of the source pane, numbered and
+// cut to width cells, with the text of it highlighted.
+func (e *explorer) sourceRow(line, width, textWidth int, it item) string {
+	if line >= e.src.LineCount() || width < exploreGutter {
+		return strings.Repeat(" ", width)
+	}
+	var sb strings.Builder
+	sb.WriteString(colorGray + fmt.Sprintf("%*d ", exploreGutter-1, line+1) + colorReset)
+
+	// Empty items, such as EOF, are highlighted as one cell.
+	hs, he := it.start, max(it.end, it.start+1)
+	s, start := e.lineText(line)
+	cells, vcol := 0, 0
+	reverse := false
+	put := func(off int, c string) {
+		if vcol >= e.srcLeft && cells < textWidth {
+			if hl := off >= hs && off < he; hl != reverse {
+				reverse = hl
+				if hl {
+					sb.WriteString(termReverse)
+				} else {
+					sb.WriteString(termNoReverse)
+				}
+			}
+			sb.WriteString(c)
+			cells++
+		}
+		vcol++
+	}
+	for i, r := range s {
+		switch {
+		case r == '\t':
+			for n := e.tabWidth - vcol%e.tabWidth; n > 0; n-- {
+				put(start+i, " ")
+			}
+		case r < ' ' || r == '\x7f':
+			put(start+i, "?")
+		default:
+			put(start+i, string(r))
+		}
+	}
+	// Show the highlighted line terminator or end of the input.
+	put(start+len(s), " ")
+	if reverse {
+		sb.WriteString(termNoReverse)
+	}
+	sb.WriteString(strings.Repeat(" ", textWidth-cells))
+	return sb.String()
+}
+
+// listRow returns the given row of the token pane, cut to width
+// cells.
+func (e *explorer) listRow(i, width int) string {
+	if i >= len(e.items) {
+		return strings.Repeat(" ", width)
+	}
+	it := e.items[i]
+	val := it.Val()
+	if it.typ != itemError {
+		val = strconv.Quote(val)
+	}
+	row := pad(fmt.Sprintf(" %d:%d", it.line, it.col), 9) + pad(it.typ.String(), 13) + val
+	row = pad(row, width)
+	if i == e.sel {
+		return termReverse + row + termNoReverse
+	}
+	return row
+}
+
+// pad returns s cut or padded with spaces to width runes.
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxFetchSize is the maximum size of the files fetched from
+	// URLs.
+	maxFetchSize = 10 << 20
+
+	// fetchTimeout is the maximum time spent fetching a file.
+	fetchTimeout = 30 * time.Second
+)
+
+// isURL reports whether the command line argument name is an HTTP or
+// HTTPS URL rather than a file name.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// fetch returns the body of the file at the given URL. Reading it
+// fails if it is larger than maxFetchSize or takes longer than
+// fetchTimeout.
+func fetch(url string) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength > maxFetchSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch %s: file larger than %d bytes", url, maxFetchSize)
+	}
+	return &limitedBody{ReadCloser: resp.Body, url: url, n: maxFetchSize}, nil
+}
+
+// limitedBody is the body of a response that fails when more than n
+// bytes are read from it.
+type limitedBody struct {
+	io.ReadCloser
+	url string
+	n   int64 // number of bytes left.
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.n < 0 {
+		return 0, fmt.Errorf("fetch %s: file larger than %d bytes", b.url, maxFetchSize)
+	}
+	// Read one byte more than allowed to detect larger files.
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	if b.n < 0 {
+		return n + int(b.n), fmt.Errorf("fetch %s: file larger than %d bytes", b.url, maxFetchSize)
+	}
+	return n, err
+}
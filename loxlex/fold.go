@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// fold returns stmts with their constant expressions folded, such as
+// 1 + 2 * 3 into 7. The syntax trees of stmts are modified in place.
+//
+// Operations on literals are evaluated as the interpreter would:
+// arithmetic and comparisons of numbers, concatenation of strings,
+// equality, negation and grouping. Logical, conditional and comma
+// expressions whose first operand is a literal are replaced by the
+// operand that the interpreter would evaluate. Operations that would
+// fail at run time, such as adding a number to a string, and those
+// whose result is not a finite number, such as a division by zero,
+// are kept as they are.
+//
+// The folded literals span the expressions they replace, and their
+// Raw text is their value as written by literalString.
+func fold(stmts []ast.Stmt) []ast.Stmt {
+	b := &ast.Block{Stmts: stmts}
+	ast.Apply(b, nil, func(c *ast.Cursor) bool {
+		// The children have been folded already.
+		if e, ok := c.Node().(ast.Expr); ok {
+			if f := foldExpr(e); f != e {
+				c.Replace(f)
+			}
+		}
+		return true
+	})
+	return b.Stmts
+}
+
+// foldExpr returns the folded form of e, whose operands are already
+// folded, or e itself if it cannot be folded.
+func foldExpr(e ast.Expr) ast.Expr {
+	switch e := e.(type) {
+	case *ast.Grouping:
+		if x, ok := e.X.(*ast.Literal); ok {
+			return foldedLiteral(e, x.Value)
+		}
+	case *ast.Unary:
+		x, ok := e.X.(*ast.Literal)
+		if !ok {
+			break
+		}
+		switch e.Op {
+		case "!":
+			return foldedLiteral(e, !truthy(x.Value))
+		case "-":
+			if n, ok := x.Value.(float64); ok {
+				return foldedLiteral(e, -n)
+			}
+		}
+	case *ast.Binary:
+		x, ok := e.X.(*ast.Literal)
+		if !ok {
+			break
+		}
+		if e.Op == "," {
+			// The left operand has no side effects.
+			return e.Y
+		}
+		y, ok := e.Y.(*ast.Literal)
+		if !ok {
+			break
+		}
+		if v, ok := foldBinary(e.Op, x.Value, y.Value); ok {
+			return foldedLiteral(e, v)
+		}
+	case *ast.Logical:
+		x, ok := e.X.(*ast.Literal)
+		if !ok {
+			break
+		}
+		// The value of a logical expression is that of the operand
+		// that decides it.
+		if truthy(x.Value) == (e.Op == "or") {
+			return foldedLiteral(e, x.Value)
+		}
+		return e.Y
+	case *ast.Conditional:
+		cond, ok := e.Cond.(*ast.Literal)
+		if !ok {
+			break
+		}
+		if truthy(cond.Value) {
+			return e.Then
+		}
+		return e.Else
+	}
+	return e
+}
+
+// foldBinary returns the value of the binary operation op on the
+// values x and y, and whether it can be folded.
+func foldBinary(op string, x, y any) (any, bool) {
+	switch op {
+	case "==":
+		return x == y, true
+	case "!=":
+		return x != y, true
+	}
+
+	if op == "+" {
+		if a, ok := x.(string); ok {
+			b, ok := y.(string)
+			return a + b, ok
+		}
+	}
+	a, ok := x.(float64)
+	if !ok {
+		return nil, false
+	}
+	b, ok := y.(float64)
+	if !ok {
+		return nil, false
+	}
+	var v any
+	switch op {
+	case "+":
+		v = a + b
+	case "-":
+		v = a - b
+	case "*":
+		v = a * b
+	case "/":
+		v = a / b
+	case "<":
+		return a < b, true
+	case "<=":
+		return a <= b, true
+	case ">":
+		return a > b, true
+	case ">=":
+		return a >= b, true
+	default:
+		return nil, false
+	}
+	if n := v.(float64); math.IsInf(n, 0) || math.IsNaN(n) {
+		return nil, false
+	}
+	return v, true
+}
+
+// truthy reports whether v is true in a condition. As in Ruby, nil
+// and false are false and every other value is true.
+func truthy(v any) bool {
+	return v != nil && v != false
+}
+
+// foldedLiteral returns the literal of value v that replaces e.
+func foldedLiteral(e ast.Expr, v any) *ast.Literal {
+	return &ast.Literal{ValuePos: e.Pos(), ValueEnd: e.End(), Value: v, Raw: literalString(v)}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"print 1 + 2 * 3;", "(print 7)"},
+		{"print (1 + 2) * 3;", "(print 9)"},
+		{"print -(4 - 6) / 4;", "(print 0.5)"},
+		{`print "a" + "b" + "c";`, `(print "abc")`},
+		{"print 1 < 2 == !false;", "(print true)"},
+		{`print 1 == "1"; print nil == nil; print "a" != "a";`, "(print false)\n(print true)\n(print false)"},
+		{"print !nil; print !0; print !\"\";", "(print true)\n(print false)\n(print false)"},
+		{"print true and a; print false and a;", "(print a)\n(print false)"},
+		{"print nil or a; print 1 or a;", "(print a)\n(print 1)"},
+		{"print true ? a : b; print nil ? a : b;", "(print a)\n(print b)"},
+		{"print (1, a);", "(print (group a))"},
+		{"var x = a + 1 * 2;", "(var x = (+ a 2))"},
+		{"f(2 * 3).x = 1 + 1;", "(; (= (call f 6) x 2))"},
+		{"while (1 < 2) print 3 - 3;", "(while true (print 0))"},
+
+		// Failures at run time and results that are not finite
+		// numbers are not folded.
+		{`print 1 + "a";`, `(print (+ 1 "a"))`},
+		{"print -nil;", "(print (- nil))"},
+		{"print 1 / 0;", "(print (/ 1 0))"},
+		{"print 1 < a + 1;", "(print (< 1 (+ a 1)))"},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		if got := sexprs(fold(stmts)); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/jroimartin/poc/loxlex
 
-go 1.21.3
+go 1.23
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// hexdumpPrinter prints the items like tablePrinter, each one followed
+// by a hex dump of its bytes, in order to find unexpected characters
+// such as byte order marks, smart quotes or non-breaking spaces. Text
+// other than whitespace between the items is dumped too.
+type hexdumpPrinter struct {
+	*tablePrinter
+	text string // text of the current input.
+	last int    // end of the last item.
+}
+
+func (p *hexdumpPrinter) start(name string) error {
+	p.text, p.last = "", 0
+	return p.tablePrinter.start(name)
+}
+
+func (p *hexdumpPrinter) source(text string) {
+	p.text = text
+}
+
+func (p *hexdumpPrinter) print(it item) error {
+	if it.start > p.last && it.start <= len(p.text) {
+		if gap := p.text[p.last:it.start]; strings.Trim(gap, " \t\r\n") != "" {
+			fmt.Fprintf(p.w, "%-8s %s\n", "", "(between tokens)")
+			p.dump(p.last, gap)
+		}
+	}
+	if err := p.tablePrinter.print(it); err != nil {
+		return err
+	}
+	if it.end <= len(p.text) {
+		p.dump(it.start, p.text[it.start:it.end])
+		p.last = max(p.last, it.end)
+	}
+	return nil
+}
+
+// dump writes a hex dump of s, which starts at offset off of the
+// input, with 16 bytes per line, like hexdump -C.
+func (p *hexdumpPrinter) dump(off int, s string) {
+	for len(s) > 0 {
+		n := min(len(s), 16)
+		var hex, ascii strings.Builder
+		for i := range 16 {
+			if i == 8 {
+				hex.WriteByte(' ')
+			}
+			if i >= n {
+				hex.WriteString("   ")
+				continue
+			}
+			c := s[i]
+			fmt.Fprintf(&hex, "%02x ", c)
+			if c < ' ' || c > '~' {
+				c = '.'
+			}
+			ascii.WriteByte(c)
+		}
+		line := fmt.Sprintf("%-8s %08x  %s |%s|", "", off, hex.String(), ascii.String())
+		if p.color {
+			line = colorGray + line + colorReset
+		}
+		fmt.Fprintln(p.w, line)
+		off += n
+		s = s[n:]
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// histogramWidth is the width of the longest bar of a histogram.
+	histogramWidth = 50
+	// histogramTop is the number of identifiers shown in a histogram.
+	histogramTop = 20
+)
+
+// histogramPrinter prints the frequency of the types of the items of
+// all the inputs, and of the most frequent identifiers, as ASCII bar
+// charts instead of the items themselves.
+type histogramPrinter struct {
+	w      io.Writer
+	types  map[string]int
+	idents map[string]int
+}
+
+func newHistogramPrinter(w io.Writer) *histogramPrinter {
+	return &histogramPrinter{
+		w:      w,
+		types:  make(map[string]int),
+		idents: make(map[string]int),
+	}
+}
+
+func (p *histogramPrinter) start(name string) error {
+	return nil
+}
+
+func (p *histogramPrinter) print(it item) error {
+	switch it.typ {
+	case itemEOF:
+	case itemIdentifier:
+		p.idents[it.Val()]++
+		fallthrough
+	default:
+		p.types[it.typ.String()]++
+	}
+	return nil
+}
+
+func (p *histogramPrinter) close() error {
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Type\tCount\tHistogram\n")
+	writeHistogram(tw, p.types, len(p.types))
+	fmt.Fprintf(tw, "\nIdentifier\tCount\tHistogram\n")
+	writeHistogram(tw, p.idents, histogramTop)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if bw, ok := p.w.(*bufio.Writer); ok {
+		return bw.Flush()
+	}
+	return nil
+}
+
+// writeHistogram writes the n most frequent keys of counts to w, from
+// the most to the least frequent, followed by their counts and bars
+// proportional to them.
+func writeHistogram(w io.Writer, counts map[string]int, n int) {
+	keys := make([]string, 0, len(counts))
+	most := 0
+	for k, c := range counts {
+		keys = append(keys, k)
+		most = max(most, c)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	for _, k := range keys[:min(n, len(keys))] {
+		c := counts[k]
+		// Every key gets at least one mark.
+		bar := strings.Repeat("#", max(1, c*histogramWidth/most))
+		fmt.Fprintf(w, "%s\t%d\t%s\n", k, c, bar)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strings"
+)
+
+// htmlStyle is the default stylesheet of the HTML output.
+const htmlStyle = `<style>
+pre.lox { background: #fdfdfd; color: #222; padding: 0.5em; }
+pre.lox .keyword { color: #a626a4; font-weight: bold; }
+pre.lox .literal.string, pre.lox .literal.rawstring, pre.lox .literal.stringpart { color: #50a14f; }
+pre.lox .literal.number { color: #986801; }
+pre.lox .operator { color: #0184bc; }
+pre.lox .punctuation { color: #696c77; }
+pre.lox .trivia { color: #a0a1a7; font-style: italic; }
+pre.lox .error { color: #e45649; text-decoration: underline wavy; }
+</style>
+`
+
+// htmlPrinter prints the source text of the inputs as HTML, wrapping
+// the tokens in span elements with the category and the type of the
+// token as classes, such as <span class="keyword var">.
+type htmlPrinter struct {
+	w     *bufio.Writer
+	text  string // source text of the current input.
+	items []item // items of the current input.
+	n     int    // number of inputs so far.
+}
+
+func newHTMLPrinter(w io.Writer) *htmlPrinter {
+	p := &htmlPrinter{w: bufio.NewWriter(w)}
+	p.w.WriteString(htmlStyle)
+	return p
+}
+
+func (p *htmlPrinter) start(name string) error {
+	p.flush()
+	p.n++
+	return nil
+}
+
+func (p *htmlPrinter) source(text string) {
+	p.text = text
+}
+
+func (p *htmlPrinter) print(it item) error {
+	if it.typ != itemEOF {
+		p.items = append(p.items, it)
+	}
+	return nil
+}
+
+// flush prints the current input, if any.
+func (p *htmlPrinter) flush() {
+	if p.n == 0 {
+		return
+	}
+	// Errors are emitted before the items containing them, such as
+	// an invalid escape sequence within a string. Those are not
+	// wrapped, as spans cannot overlap.
+	slices.SortStableFunc(p.items, func(a, b item) int {
+		return cmp.Or(cmp.Compare(a.start, b.start), cmp.Compare(b.end, a.end))
+	})
+	p.w.WriteString(`<pre class="lox">`)
+	pos := 0
+	for _, it := range p.items {
+		if it.start < pos || it.end > len(p.text) {
+			continue
+		}
+		p.w.WriteString(html.EscapeString(p.text[pos:it.start]))
+		class := it.typ.Category().String() + " " + strings.ToLower(it.typ.String())
+		title := ""
+		if it.typ == itemError {
+			class = "error"
+			title = fmt.Sprintf(` title="%s"`, html.EscapeString(it.Val()))
+		}
+		fmt.Fprintf(p.w, `<span class="%s"%s>%s</span>`, class, title, html.EscapeString(p.text[it.start:it.end]))
+		pos = it.end
+	}
+	p.w.WriteString(html.EscapeString(p.text[pos:]))
+	p.w.WriteString("</pre>\n")
+	p.text, p.items = "", nil
+}
+
+func (p *htmlPrinter) close() error {
+	p.flush()
+	return p.w.Flush()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// Interner is a table of interned strings. Interning a string returns
+// a canonical copy of it, so equal strings share their storage and
+// can be used as symbol identities. An Interner is safe for concurrent
+// use, so it can be shared by a lexer and its client.
+type Interner struct {
+	mu   sync.Mutex
+	strs map[string]string
+}
+
+// NewInterner returns an empty Interner.
+func NewInterner() *Interner {
+	return &Interner{strs: make(map[string]string)}
+}
+
+// Intern returns the canonical copy of s, adding it to the table if
+// it is not there. The table does not retain s itself, so interning a
+// substring of a large input does not keep the input alive.
+func (in *Interner) Intern(s string) string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if c, ok := in.strs[s]; ok {
+		return c
+	}
+	c := strings.Clone(s)
+	in.strs[c] = c
+	return c
+}
+
+// Lookup returns the canonical copy of s and whether it has been
+// interned.
+func (in *Interner) Lookup(s string) (string, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	c, ok := in.strs[s]
+	return c, ok
+}
+
+// Len returns the number of strings in the table.
+func (in *Interner) Len() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	return len(in.strs)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// jsonItem is the JSON representation of an item.
+type jsonItem struct {
+	File  string `json:"file,omitempty"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Lit   string `json:"literal,omitempty"`
+	Line  int    `json:"line"`
+	Col   int    `json:"col"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// newJSONItem returns the JSON representation of it, scanned from the
+// named input. The name is omitted if empty.
+func newJSONItem(name string, it item) jsonItem {
+	return jsonItem{
+		File:  name,
+		Type:  it.typ.String(),
+		Value: it.Val(),
+		Lit:   it.lit,
+		Line:  it.line,
+		Col:   it.col,
+		Start: it.start,
+		End:   it.end,
+	}
+}
+
+// jsonWriter writes JSON objects as the elements of an array or, in
+// NDJSON mode, one per line.
+type jsonWriter struct {
+	w      *bufio.Writer
+	ndjson bool
+	stream bool // flush the output after every object.
+	n      int  // number of objects written.
+	buf    bytes.Buffer
+	enc    *json.Encoder // encoder writing to buf.
+}
+
+// newJSONWriter returns a jsonWriter that writes to w. close must be
+// called after the last object.
+func newJSONWriter(w io.Writer, ndjson bool) *jsonWriter {
+	jw := &jsonWriter{w: bufio.NewWriter(w), ndjson: ndjson}
+	jw.enc = json.NewEncoder(&jw.buf)
+	jw.enc.SetEscapeHTML(false)
+	return jw
+}
+
+// write writes v.
+func (jw *jsonWriter) write(v any) error {
+	jw.buf.Reset()
+	if err := jw.enc.Encode(v); err != nil {
+		return err
+	}
+	b := bytes.TrimSuffix(jw.buf.Bytes(), []byte("\n"))
+	switch {
+	case jw.ndjson:
+	case jw.n == 0:
+		jw.w.WriteString("[\n")
+	default:
+		jw.w.WriteString(",\n")
+	}
+	jw.w.Write(b)
+	if jw.ndjson {
+		jw.w.WriteString("\n")
+	}
+	jw.n++
+	if jw.stream {
+		return jw.w.Flush()
+	}
+	return nil
+}
+
+// close terminates the array, if any, and flushes the output.
+func (jw *jsonWriter) close() error {
+	if !jw.ndjson {
+		if jw.n == 0 {
+			jw.w.WriteString("[")
+		}
+		jw.w.WriteString("\n]\n")
+	}
+	return jw.w.Flush()
+}
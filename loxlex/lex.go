@@ -1,20 +1,39 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
 	"unicode/utf8"
 )
 
 // item represents a token returned by the scanner.
 type item struct {
-	typ itemType // Type, such as itemNumber.
-	val string   // Value, such as "23.2".
+	typ   itemType // Type, such as itemNumber.
+	src   *source  // Input the item was scanned from. See Val.
+	lit   string   // Literal value, such as the unescaped string, interned word, or error message.
+	base  int      // Base of number literals, such as 16.
+	lead  []trivia // Trivia preceding this item. See withTrivia.
+	trail []trivia // Trivia following this item on the same line.
+	err   error    // Error, for itemError. It is a *LexError.
+	line  int      // Line number at the start of this item.
+	col   int      // Byte column at the start of this item.
+	start int      // Byte offset of the start of this item.
+	end   int      // Byte offset just past the end of this item.
 }
 
 // itemType identifies the type of lex items.
 type itemType int
 
+//go:generate stringer -type=itemType -trimprefix=item
+
 // Lex item types.
 const (
 	// Error occurred; value is text of error.
@@ -32,6 +51,8 @@ const (
 	itemSemicolon
 	itemSlash
 	itemStar
+	itemQuestion
+	itemColon
 
 	// One or two character tokens.
 	itemBang
@@ -46,6 +67,7 @@ const (
 	// Literals.
 	itemIdentifier
 	itemString
+	itemRawString
 	itemNumber
 
 	// Keywords.
@@ -65,64 +87,27 @@ const (
 	itemTrue
 	itemVar
 	itemWhile
+	itemKeyword // Reserved word added with withKeywords.
+
+	// Interpolated strings. Only emitted with withInterpolation.
+	itemStringPart  // Piece of literal text of an interpolated string.
+	itemInterpStart // Start of an embedded expression: "${".
+	itemInterpEnd   // End of an embedded expression: "}".
+
+	// Comments. Only emitted with withComments or, for doc
+	// comments, withDocComments.
+	itemComment
+	itemDocComment // Comment starting with exactly three slashes.
 
 	// End of file.
 	itemEOF
 )
 
-// itemNames associates item types with the corresponding string
-// representations.
-var itemNames = map[itemType]string{
-	itemError:        "Error",
-	itemLeftParen:    "LeftParen",
-	itemRightParen:   "RightParen",
-	itemLeftBrace:    "LeftBrace",
-	itemRightBrace:   "RightBrace",
-	itemComma:        "Comma",
-	itemDot:          "Dot",
-	itemMinus:        "Minus",
-	itemPlus:         "Plus",
-	itemSemicolon:    "Semicolon",
-	itemSlash:        "Slash",
-	itemStar:         "Star",
-	itemBang:         "Bang",
-	itemBangEqual:    "BangEqual",
-	itemEqual:        "Equal",
-	itemEqualEqual:   "EqualEqual",
-	itemGreater:      "Greater",
-	itemGreaterEqual: "GreaterEqual",
-	itemLess:         "Less",
-	itemLessEqual:    "LessEqual",
-	itemIdentifier:   "Identifier",
-	itemString:       "String",
-	itemNumber:       "Number",
-	itemAnd:          "And",
-	itemClass:        "Class",
-	itemElse:         "Else",
-	itemFalse:        "False",
-	itemFun:          "Fun",
-	itemFor:          "For",
-	itemIf:           "If",
-	itemNil:          "Nil",
-	itemOr:           "Or",
-	itemPrint:        "Print",
-	itemReturn:       "Return",
-	itemSuper:        "Super",
-	itemThis:         "This",
-	itemTrue:         "True",
-	itemVar:          "Var",
-	itemWhile:        "While",
-	itemEOF:          "EOF",
-}
-
-func (t itemType) String() string {
-	if s, ok := itemNames[t]; ok {
-		return s
-	}
-	return "unknown"
-}
-
-// key associates keywords with the corresponding item types.
+// key associates keywords with the corresponding item types. The
+// function keyword, which recognizes them without hashing, is
+// generated from it.
+//
+//go:generate go run genkeyword.go
 var key = map[string]itemType{
 	"and":    itemAnd,
 	"class":  itemClass,
@@ -142,14 +127,59 @@ var key = map[string]itemType{
 	"while":  itemWhile,
 }
 
+// trivia is a piece of input that does not affect the meaning of the
+// program, such as whitespace or a comment.
+type trivia struct {
+	kind  triviaKind // Kind, such as triviaComment.
+	val   string     // Value, such as "// TODO".
+	start int        // Byte offset of the start of this trivia.
+}
+
+// triviaKind identifies the kind of trivia.
+type triviaKind int
+
+// Trivia kinds.
+const (
+	triviaSpace   triviaKind = iota // Run of blanks other than newlines.
+	triviaNewline                   // Newline.
+	triviaComment                   // Line or block comment.
+	triviaShebang                   // Shebang line.
+	triviaBOM                       // Byte order mark.
+)
+
+// source is a piece of the input shared by the items scanned from it,
+// so items do not need to hold their own copy of their values.
+type source struct {
+	text string // Input.
+	off  int    // Offset of text in the whole input.
+}
+
+// Val returns the value of the item, such as "23.2". For itemError,
+// it is the error message. For identifiers and keywords scanned with
+// withInterning, it is the interned word.
+func (i item) Val() string {
+	if i.typ == itemError || i.lit != "" && i.isWord() {
+		return i.lit
+	}
+	if i.src == nil {
+		return ""
+	}
+	return i.src.text[i.start-i.src.off : i.end-i.src.off]
+}
+
+// isWord returns whether the item is an identifier or a keyword.
+func (i item) isWord() bool {
+	return i.typ == itemIdentifier || i.typ.Category() == CategoryKeyword
+}
+
 func (i item) String() string {
 	switch i.typ {
 	case itemEOF:
 		return "EOF"
 	case itemError:
-		return i.val
+		return i.Val()
 	}
-	return fmt.Sprintf("%q", i.val)
+	return fmt.Sprintf("%q", i.Val())
 }
 
 // stateFn represents the state of the scanner as a function that
@@ -158,38 +188,339 @@ type stateFn func(*lexer) stateFn
 
 // lexer holds the state of the scanner.
 type lexer struct {
-	input string    // the string being scanned.
-	start int       // start position of this item.
-	pos   int       // current position in the input.
-	width int       // width of last rune read from input.
-	items chan item // channel of scanned items.
+	input     string          // the string being scanned.
+	src       *source         // input shared with the emitted items.
+	start     int             // start position of this item.
+	pos       int             // current position in the input.
+	width     int             // width of last rune read from input.
+	items     chan item       // channel of scanned items.
+	line      int             // 1+number of newlines seen.
+	lineStart int             // position of the first byte of the current line.
+	prevStart int             // lineStart before the last newline; used by backup.
+	vcolBase  int             // visual column of input[0] if its line starts before it.
+	nl        bool            // whether the last rune read ends a line.
+	startLine int             // line at the start of this item.
+	startCol  int             // column at the start of this item.
+	r         io.Reader       // source of the input when lexing a stream.
+	off       int             // stream offset of the first byte of input.
+	rerr      error           // read error, if any, other than io.EOF.
+	yield     func(item) bool // if not nil, receives items instead of the channel.
+	halt      bool            // no more items must be delivered.
+	done      <-chan struct{} // closed when the client abandons the channel.
+	diags     []Diagnostic    // diagnostics reported so far.
+	lead      []trivia        // trivia for the next item.
+	held      item            // item waiting for its trailing trivia.
+	holding   bool            // whether held is valid.
+	opts      LexerOptions    // configuration of the lexer.
+	errors    int             // number of errors reported.
+	interp    []interpolation // embedded expressions being scanned, innermost last.
+	capped    bool            // whether the item being scanned is subject to MaxTokenLen.
+}
+
+// interpolation is an embedded expression of an interpolated string.
+type interpolation struct {
+	pos   Position // position of its opening "${".
+	depth int      // number of braces open in it.
 }
 
 // lex initializes the lexer to lex an input string and launches the
 // state machine as a goroutine. It returns a channel of scanned
 // items.
-func lex(input string) <-chan item {
+func lex(input string, opts ...option) <-chan item {
+	l := newLexer(input, opts...)
+	l.items = make(chan item, l.chanSize())
+	go l.run()
+	return l.items
+}
+
+// lexContext is like [lex] but stops scanning when ctx is done, so
+// the goroutine does not leak if the client stops receiving items
+// before the channel is closed.
+func lexContext(ctx context.Context, input string, opts ...option) <-chan item {
+	l := newLexer(input, opts...)
+	l.items = make(chan item, l.chanSize())
+	l.done = ctx.Done()
+	go l.run()
+	return l.items
+}
+
+// newLexer returns a lexer positioned at the beginning of input.
+func newLexer(input string, opts ...option) *lexer {
 	l := &lexer{
-		input: input,
-		items: make(chan item),
+		input:     input,
+		line:      1,
+		startLine: 1,
+		startCol:  1,
+	}
+	for _, opt := range opts {
+		opt(&l.opts)
 	}
+	if l.opts.FoldKeywords && l.opts.Keywords != nil {
+		folded := make(map[string]itemType, len(l.opts.Keywords))
+		for w, t := range l.opts.Keywords {
+			folded[lowerASCII(w)] = t
+		}
+		l.opts.Keywords = folded
+	}
+	return l
+}
+
+// Bounds of the capacity of the items channel chosen by ChanSizeAuto.
+const (
+	minAutoChanSize = 16
+	maxAutoChanSize = 1024
+)
+
+// chanSize returns the capacity of the items channel. When it is
+// chosen automatically, it grows with the size of the input, assuming
+// an average of 8 bytes per item.
+func (l *lexer) chanSize() int {
+	if l.opts.ChanSize != ChanSizeAuto {
+		return l.opts.ChanSize
+	}
+	if l.r != nil {
+		// The size of a stream is unknown.
+		return maxAutoChanSize
+	}
+	return min(max(len(l.input)/8, minAutoChanSize), maxAutoChanSize)
+}
+
+// readChunk is the number of bytes requested from the underlying
+// reader each time the lexer runs out of input.
+const readChunk = 4096
+
+// lexReader is like [lex] but consumes the input incrementally from
+// r, keeping in memory only the item being scanned.
+func lexReader(r io.Reader, opts ...option) <-chan item {
+	l := newLexer("", opts...)
+	l.r = r
+	l.items = make(chan item, l.chanSize())
 	go l.run()
 	return l.items
 }
 
+// ChanLexer is a handle to a lexer running in its own goroutine.
+// Unlike the channels returned by [lex] and [lexReader], it lets the
+// client give up on the items early without leaking the goroutine.
+type ChanLexer struct {
+	l    *lexer
+	stop chan struct{}
+	once sync.Once
+}
+
+// StartLexer launches a lexer for the input string in its own
+// goroutine.
+func StartLexer(input string, opts ...option) *ChanLexer {
+	return startLexer(newLexer(input, opts...))
+}
+
+// StartReaderLexer is like [StartLexer] but consumes the input
+// incrementally from r, as [lexReader] does.
+func StartReaderLexer(r io.Reader, opts ...option) *ChanLexer {
+	l := newLexer("", opts...)
+	l.r = r
+	return startLexer(l)
+}
+
+// startLexer launches l in its own goroutine.
+func startLexer(l *lexer) *ChanLexer {
+	cl := &ChanLexer{l: l, stop: make(chan struct{})}
+	l.items = make(chan item, l.chanSize())
+	l.done = cl.stop
+	go l.run()
+	return cl
+}
+
+// Items returns the channel of scanned items. It is closed when the
+// scan finishes.
+func (cl *ChanLexer) Items() <-chan item {
+	return cl.l.items
+}
+
+// Stop terminates the scan and waits for the goroutine to exit. The
+// items not received yet are discarded. A scan blocked reading from
+// the underlying reader finishes when the read returns. Stop may be
+// called more than once.
+func (cl *ChanLexer) Stop() {
+	cl.once.Do(func() { close(cl.stop) })
+	for range cl.l.items {
+	}
+}
+
+// Drain waits for the scan to finish and returns the items not
+// received yet.
+func (cl *ChanLexer) Drain() []item {
+	var items []item
+	for it := range cl.l.items {
+		items = append(items, it)
+	}
+	return items
+}
+
+// Diagnostics returns the diagnostics reported by the scan. It must
+// not be called until the items channel is closed, such as after
+// Stop or Drain.
+func (cl *ChanLexer) Diagnostics() []Diagnostic {
+	return cl.l.diags
+}
+
+// Lex returns an iterator over the items of the input string. The
+// state machine runs in the calling goroutine, so breaking out of the
+// loop early simply stops the scan.
+func Lex(input string, opts ...option) iter.Seq[item] {
+	return func(yield func(item) bool) {
+		l := newLexer(input, opts...)
+		l.yield = yield
+		l.scan()
+	}
+}
+
+// Lexer scans an input string on demand. Unlike [lex], it does not
+// launch a goroutine: each call to [Lexer.Next] runs the state machine
+// just until the next item is available.
+type Lexer struct {
+	l     *lexer
+	state stateFn
+	queue []item // items emitted but not yet returned by Next.
+	last  item   // last item returned by Next.
+}
+
+// NewLexer returns a Lexer for the input string.
+func NewLexer(input string, opts ...option) *Lexer {
+	lx := &Lexer{state: lexStart}
+	lx.l = newLexer(input, opts...)
+	lx.l.yield = func(it item) bool {
+		lx.queue = append(lx.queue, it)
+		return true
+	}
+	return lx
+}
+
+// Diagnostics returns the diagnostics reported so far.
+func (lx *Lexer) Diagnostics() []Diagnostic {
+	return lx.l.diags
+}
+
+// Next returns the next item of the input. Once the scan has finished,
+// either with EOF or with an error, Next keeps returning the last
+// item.
+func (lx *Lexer) Next() item {
+	for len(lx.queue) == 0 && lx.state != nil && !lx.l.halt {
+		lx.state = lx.l.step(lx.state)
+	}
+	if len(lx.queue) == 0 {
+		return lx.last
+	}
+	lx.last = lx.queue[0]
+	lx.queue = lx.queue[1:]
+	return lx.last
+}
+
 // run lexes the input by executing state functions until the state is
 // nil.
 func (l *lexer) run() {
-	for state := lexCode; state != nil; {
-		state = state(l)
-	}
+	l.scan()
 	close(l.items) // No more tokens will be delivered.
 }
 
+// scan executes state functions until the state is nil or the client
+// stops accepting items.
+func (l *lexer) scan() {
+	for state := lexStart; state != nil && !l.halt; {
+		state = l.step(state)
+	}
+}
+
+// step executes state and returns the next state, updating the
+// metrics of the lexer, if any.
+func (l *lexer) step(state stateFn) stateFn {
+	m := l.opts.Metrics
+	if m == nil {
+		return state(l)
+	}
+	pos, t0 := l.off+l.pos, time.Now()
+	next := state(l)
+	m.addState(state, time.Since(t0), l.off+l.pos-pos)
+	return next
+}
+
+// send delivers an item to the client.
+func (l *lexer) send(it item) {
+	if l.halt {
+		return
+	}
+	if l.opts.Metrics != nil {
+		l.opts.Metrics.addItem(it.typ)
+	}
+	if l.yield != nil {
+		l.halt = !l.yield(it)
+		return
+	}
+	select {
+	case l.items <- it:
+	case <-l.done:
+		l.halt = true
+	}
+}
+
 // emit passes an item back to the client.
 func (l *lexer) emit(t itemType) {
-	l.items <- item{t, l.input[l.start:l.pos]}
-	l.start = l.pos
+	l.emitItem(item{typ: t})
+}
+
+// emitItem passes it back to the client after filling in its value
+// and position with those of the pending input.
+func (l *lexer) emitItem(it item) {
+	if !l.withinTokenLen() {
+		return
+	}
+	if l.src == nil {
+		l.src = &source{text: l.input, off: l.off}
+	}
+	it.src = l.src
+	it.line = l.startLine
+	it.col = l.startCol
+	it.start = l.off + l.start
+	it.end = l.off + l.pos
+	l.flush()
+	if l.opts.Trivia {
+		it.lead, l.lead = l.lead, nil
+		if it.typ != itemEOF {
+			// Wait for the trailing trivia.
+			l.held, l.holding = it, true
+			l.ignore()
+			return
+		}
+	}
+	l.send(it)
+	l.ignore()
+}
+
+// flush passes back to the client the item waiting for its trailing
+// trivia, if any.
+func (l *lexer) flush() {
+	if l.holding {
+		l.send(l.held)
+		l.held, l.holding = item{}, false
+	}
+}
+
+// skip skips over the pending input, which is trivia of the given
+// kind. If the lexer keeps trivia, it is attached to the adjacent
+// item.
+func (l *lexer) skip(kind triviaKind) {
+	if l.opts.Trivia {
+		t := trivia{kind: kind, val: l.input[l.start:l.pos], start: l.off + l.start}
+		if l.holding {
+			l.held.trail = append(l.held.trail, t)
+			if kind == triviaNewline {
+				l.flush()
+			}
+		} else {
+			l.lead = append(l.lead, t)
+		}
+	}
+	l.ignore()
 }
 
 // eof represents end of file.
@@ -197,24 +528,171 @@ const eof = -1
 
 // next returns the next rune in the input.
 func (l *lexer) next() (r rune) {
+	if l.r != nil && !utf8.FullRuneInString(l.input[l.pos:]) {
+		l.fill(0)
+	}
 	if l.pos >= len(l.input) {
 		l.width = 0
 		return eof
 	}
+	if !l.withinLimits() {
+		l.width = 0
+		return eof
+	}
 	r, l.width = utf8.DecodeRuneInString(l.input[l.pos:])
 	l.pos += l.width
+	// Lines end with "\n", "\r\n" or a lone "\r".
+	l.nl = r == '\n' || r == '\r' && l.peek() != '\n'
+	if l.nl {
+		l.line++
+		l.prevStart = l.lineStart
+		l.lineStart = l.pos
+		if l.opts.Source != nil {
+			l.opts.Source.AddLine(l.off + l.pos)
+		}
+	}
 	return r
 }
 
+// withinLimits reports whether the next rune can be read without
+// exceeding the size limits of the lexer. If it cannot, the error is
+// reported and the scan is terminated.
+func (l *lexer) withinLimits() bool {
+	if l.opts.MaxInput > 0 && l.off+l.pos >= l.opts.MaxInput {
+		l.report(l.start, ErrInputTooLarge, "input exceeds %d bytes", l.opts.MaxInput)
+		l.halt = true
+		return false
+	}
+	// The rune that follows an item may be read to find its end, so
+	// the item is checked again when it is emitted.
+	return l.withinTokenLen()
+}
+
+// withinTokenLen reports whether the pending input does not exceed
+// the maximum length of the item being scanned, if it is an
+// identifier, a string or a number. If it does, the error is reported
+// and the scan is terminated.
+func (l *lexer) withinTokenLen() bool {
+	if !l.capped || l.opts.MaxTokenLen <= 0 || l.pos-l.start <= l.opts.MaxTokenLen {
+		return true
+	}
+	l.report(l.start, ErrTokenTooLong, "token exceeds %d bytes", l.opts.MaxTokenLen)
+	l.halt = true
+	return false
+}
+
+// peek returns but does not consume the next rune in the input.
+func (l *lexer) peek() rune {
+	return l.peekN(1)
+}
+
+// peekN returns but does not consume the nth rune after the current
+// position, so peekN(1) is equivalent to peek. It returns eof if there
+// are fewer than n runes left.
+func (l *lexer) peekN(n int) rune {
+	r, ahead := rune(eof), 0
+	for ; n > 0; n-- {
+		if l.r != nil && !utf8.FullRuneInString(l.input[l.pos+ahead:]) {
+			l.fill(ahead)
+		}
+		if l.pos+ahead >= len(l.input) {
+			return eof
+		}
+		var w int
+		r, w = utf8.DecodeRuneInString(l.input[l.pos+ahead:])
+		ahead += w
+	}
+	return r
+}
+
+// fill reads from the underlying reader until a full rune is
+// available ahead bytes past the current position or the stream is
+// exhausted. The input before the start of the current item is
+// discarded, so the buffered input never grows beyond the item being
+// scanned plus one chunk.
+func (l *lexer) fill(ahead int) {
+	if n := l.start; n > 0 {
+		l.vcolBase = l.visualCol(n) - 1
+		l.input = l.input[n:]
+		l.src = nil
+		l.off += n
+		l.start -= n
+		l.pos -= n
+		l.lineStart -= n
+		l.prevStart -= n
+	}
+	buf := make([]byte, readChunk)
+	for !utf8.FullRuneInString(l.input[l.pos+ahead:]) {
+		if l.opts.MaxInput > 0 && l.off+len(l.input) > l.opts.MaxInput {
+			// Do not buffer input that will be rejected.
+			return
+		}
+		n, err := l.r.Read(buf)
+		l.input += string(buf[:n])
+		l.src = nil
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				l.rerr = err
+			}
+			l.r = nil
+			return
+		}
+	}
+}
+
 // ignore skips over the pending input before this point.
 func (l *lexer) ignore() {
+	l.capped = false
 	l.start = l.pos
+	l.startLine = l.line
+	l.startCol = l.pos - l.lineStart + 1
 }
 
 // backup steps back one rune. Can be called only once per call of
 // next.
 func (l *lexer) backup() {
 	l.pos -= l.width
+	// Correct newline count.
+	if l.nl {
+		l.line--
+		l.lineStart = l.prevStart
+		l.nl = false
+	}
+}
+
+// mark is a checkpoint in the scan. See [*lexer.mark].
+type mark struct {
+	pos       int // stream offset of the current position.
+	width     int
+	line      int
+	lineStart int // stream offset of the current line.
+	prevStart int
+	nl        bool
+}
+
+// mark returns a checkpoint of the current position, so a state
+// function can scan ahead speculatively and rewind with
+// [*lexer.reset] if it fails. A mark is valid until the next item is
+// emitted or ignored.
+func (l *lexer) mark() mark {
+	return mark{
+		pos:       l.off + l.pos,
+		width:     l.width,
+		line:      l.line,
+		lineStart: l.off + l.lineStart,
+		prevStart: l.off + l.prevStart,
+		nl:        l.nl,
+	}
+}
+
+// reset rewinds the scan to the checkpoint m.
+func (l *lexer) reset(m mark) {
+	l.pos = m.pos - l.off
+	l.width = m.width
+	l.line = m.line
+	l.lineStart = m.lineStart - l.off
+	l.prevStart = m.prevStart - l.off
+	l.nl = m.nl
 }
 
 // accept consumes the next rune if it is r.
@@ -247,18 +725,164 @@ func (l *lexer) acceptRun(f condFn) {
 
 // errorf returns an error token and terminates the scan by passing
 // back a nil pointer that will be the next state, terminating
-// [*lexer.run].
-func (l *lexer) errorf(format string, args ...any) stateFn {
-	l.items <- item{
-		itemError,
-		fmt.Sprintf(format, args...),
+// [*lexer.run]. In recovery mode, the pending input is skipped
+// instead and the scan resumes at [lexCode].
+func (l *lexer) errorf(kind error, format string, args ...any) stateFn {
+	return l.errorAt(l.start, kind, format, args...)
+}
+
+// errorAt is like [*lexer.errorf] but reports the error at position
+// pos, which must not be before the start of the current item.
+func (l *lexer) errorAt(pos int, kind error, format string, args ...any) stateFn {
+	l.report(pos, kind, format, args...)
+	if !l.resume() {
+		return nil
+	}
+	l.ignore()
+	return lexCode
+}
+
+// resume reports whether the scan must continue after an error.
+func (l *lexer) resume() bool {
+	if l.opts.MaxErrors > 0 && l.errors >= l.opts.MaxErrors {
+		return false
+	}
+	return l.opts.Recover
+}
+
+// report records a diagnostic of the given kind positioned at pos
+// and passes the corresponding error token back to the client
+// without altering the state of the scan.
+func (l *lexer) report(pos int, kind error, format string, args ...any) {
+	l.reportPos(l.offsetPos(pos), kind, format, args...)
+}
+
+// reportPos is like [*lexer.report] but reports the error at the
+// position pos, which may be before the start of the current item.
+func (l *lexer) reportPos(pos Position, kind error, format string, args ...any) {
+	if l.halt {
+		return
+	}
+	err := &LexError{
+		Kind: kind,
+		Pos:  pos,
+		Err:  fmt.Errorf(l.opts.Messages.translate(format), args...),
+	}
+	d := Diagnostic{
+		Pos:      err.Pos,
+		End:      l.offsetPos(l.pos),
+		Severity: SeverityError,
+		Code:     codes[kind],
+		Msg:      err.Err.Error(),
+	}
+	l.diags = append(l.diags, d)
+	l.errors++
+	l.flush()
+	l.send(item{
+		typ:   itemError,
+		lit:   d.Msg,
+		err:   err,
+		line:  d.Pos.Line,
+		col:   d.Pos.Col,
+		start: d.Pos.Offset,
+		end:   d.End.Offset,
+	})
+}
+
+// offsetPos returns the [Position] of pos, which must not be before
+// the start of the current item.
+func (l *lexer) offsetPos(pos int) Position {
+	line, col := l.position(pos)
+	return Position{Offset: l.off + pos, Line: line, Col: col, VCol: l.visualCol(pos)}
+}
+
+// defaultTabWidth is the distance between tab stops when
+// LexerOptions.TabWidth is zero.
+const defaultTabWidth = 8
+
+// visualCol returns the visual column of pos. Runes count as one
+// column and tabs advance to the next tab stop.
+func (l *lexer) visualCol(pos int) int {
+	tw := l.opts.TabWidth
+	if tw <= 0 {
+		tw = defaultTabWidth
+	}
+	// Find the start of the line. If it has been discarded, the
+	// count starts at the visual column of the buffered input.
+	i, vcol := pos, l.vcolBase
+	for ; i > 0; i-- {
+		if c := l.input[i-1]; c == '\n' || c == '\r' && (i == len(l.input) || l.input[i] != '\n') {
+			vcol = 0
+			break
+		}
+	}
+	for _, r := range l.input[i:pos] {
+		if r == '\t' {
+			vcol += tw - vcol%tw
+		} else {
+			vcol++
+		}
+	}
+	return vcol + 1
+}
+
+// position returns the line and column of pos, which must not be
+// before the start of the current item.
+func (l *lexer) position(pos int) (line, col int) {
+	line, col = l.startLine, l.startCol
+	for i := l.start; i < pos; i++ {
+		if c := l.input[i]; c == '\n' || c == '\r' && (i+1 == len(l.input) || l.input[i+1] != '\n') {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// bom is the byte order mark.
+const bom = '\uFEFF'
+
+// lexStart skips the byte order mark at the beginning of the input,
+// if any, and the shebang line (#!/usr/bin/env loxi) that makes Lox
+// scripts executable on Unix. In trivia mode, both are kept as
+// trivia.
+func lexStart(l *lexer) stateFn {
+	if l.accept(bom) {
+		// The byte order mark does not count for columns.
+		l.lineStart = l.pos
+		if l.opts.Source != nil {
+			l.opts.Source.lines[0] = l.off + l.pos
+		}
+		l.skip(triviaBOM)
+	}
+	if l.peek() == '#' && l.peekN(2) == '!' {
+		l.acceptRun(not(isEOL))
+		l.skip(triviaShebang)
 	}
-	return nil
+	return lexCode
 }
 
 // lexCode scans the elements in a piece of Lox code.
 func lexCode(l *lexer) stateFn {
 	switch r := l.next(); {
+	case l.invalidUTF8(r):
+		return l.errorf(ErrInvalidUTF8, "invalid UTF-8 encoding: %#x", l.input[l.start])
+	case l.opts.StrictASCII && r >= utf8.RuneSelf:
+		return l.errorf(ErrNonASCII, "non-ASCII character: %q", r)
+	case r == eof && l.rerr != nil:
+		err := l.rerr
+		l.rerr = nil
+		return l.errorf(ErrRead, "read error: %w", err)
+	case r == eof && len(l.interp) > 0:
+		pos := l.interp[len(l.interp)-1].pos
+		l.interp = nil
+		l.reportPos(pos, ErrUnclosedString, "unclosed string interpolation")
+		if !l.resume() {
+			return nil
+		}
+		return lexCode
 	case r == eof:
 		l.emit(itemEOF)
 		return nil
@@ -267,8 +891,19 @@ func lexCode(l *lexer) stateFn {
 	case r == ')':
 		l.emit(itemRightParen)
 	case r == '{':
+		if n := len(l.interp); n > 0 {
+			l.interp[n-1].depth++
+		}
 		l.emit(itemLeftBrace)
 	case r == '}':
+		if n := len(l.interp); n > 0 {
+			if l.interp[n-1].depth == 0 {
+				l.interp = l.interp[:n-1]
+				l.emit(itemInterpEnd)
+				return lexInterpolated
+			}
+			l.interp[n-1].depth--
+		}
 		l.emit(itemRightBrace)
 	case r == ',':
 		l.emit(itemComma)
@@ -282,6 +917,10 @@ func lexCode(l *lexer) stateFn {
 		l.emit(itemSemicolon)
 	case r == '*':
 		l.emit(itemStar)
+	case r == '?':
+		l.emit(itemQuestion)
+	case r == ':':
+		l.emit(itemColon)
 	case r == '!':
 		if l.accept('=') {
 			l.emit(itemBangEqual)
@@ -310,68 +949,446 @@ func lexCode(l *lexer) stateFn {
 		if l.accept('/') {
 			return lexComment
 		}
+		if l.accept('*') {
+			return lexBlockComment
+		}
 		l.emit(itemSlash)
 	case r == '"':
 		return lexQuote
+	case r == '`':
+		return lexRawQuote
+	case r == '\r' || r == '\n':
+		if r == '\r' {
+			l.accept('\n')
+		}
+		l.skip(triviaNewline)
 	case isSpace(r):
-		l.ignore()
+		l.acceptRun(isBlank)
+		l.skip(triviaSpace)
 	case unicode.IsDigit(r):
 		l.backup()
 		return lexNumber
-	case isAlpha(r):
+	case l.isIdentStart(r):
 		l.backup()
 		return lexIdentifier
 	default:
-		return l.errorf("unexpected character: %c", r)
+		return l.errorf(ErrUnexpectedChar, "unexpected character: %c", r)
 	}
 	return lexCode
 }
 
-// lexComment scans a comment.
+// lexComment scans a line comment. Comments starting with exactly
+// three slashes (///) are doc comments.
 func lexComment(l *lexer) stateFn {
+	typ := itemComment
+	if l.peek() == '/' && l.peekN(2) != '/' {
+		typ = itemDocComment
+	}
 	l.acceptRun(not(isEOL))
-	l.ignore()
+	l.comment(typ)
 	return lexCode
 }
 
-// lexQuote scans a string.
+// lexBlockComment scans a block comment. Block comments do not nest.
+func lexBlockComment(l *lexer) stateFn {
+	for {
+		switch l.next() {
+		case eof:
+			return l.errorf(ErrUnclosedComment, "unclosed comment")
+		case '*':
+			if l.accept('/') {
+				l.comment(itemComment)
+				return lexCode
+			}
+		}
+	}
+}
+
+// comment emits the pending comment as an item of type typ or skips
+// it, depending on the options of the lexer.
+func (l *lexer) comment(typ itemType) {
+	l.checkHidden()
+	if l.opts.Comments || typ == itemDocComment && l.opts.DocComments {
+		l.emit(typ)
+		return
+	}
+	l.skip(triviaComment)
+}
+
+// lexQuote scans a string. The literal value of the emitted item
+// is the contents of the string with its escape sequences replaced.
+// The supported escape sequences are \n, \t, \", \\, \u{X...} and,
+// with withInterpolation, \$.
 func lexQuote(l *lexer) stateFn {
-	switch l.next() {
-	case eof:
-		return l.errorf("unclosed string")
-	case '"':
-		l.emit(itemString)
-		return lexCode
-	default:
-		return lexQuote
+	l.capped = true
+	return l.lexString(false)
+}
+
+// lexInterpolated scans the rest of an interpolated string after an
+// embedded expression.
+func lexInterpolated(l *lexer) stateFn {
+	l.capped = true
+	return l.lexString(true)
+}
+
+// lexString scans the contents of a string up to the closing quote
+// or, if interpolation is enabled, up to the next embedded expression.
+// The pieces of text of an interpolated string are emitted as
+// itemStringPart, so "a${b}c" is emitted as StringPart("a"),
+// InterpStart, Identifier(b), InterpEnd and StringPart("c"). Strings
+// may span several lines unless withSingleLineStrings is used.
+func (l *lexer) lexString(interpolated bool) stateFn {
+	var sb strings.Builder
+	for {
+		switch r := l.next(); r {
+		case eof:
+			return l.unclosedString()
+		case '"':
+			typ := itemString
+			if interpolated {
+				typ = itemStringPart
+			}
+			l.checkHidden()
+			l.emitItem(item{typ: typ, lit: sb.String()})
+			return lexCode
+		case '$':
+			if !l.opts.Interpolation || l.peek() != '{' {
+				sb.WriteRune(r)
+				break
+			}
+			l.backup()
+			l.checkHidden()
+			l.emitItem(item{typ: itemStringPart, lit: sb.String()})
+			l.next()
+			l.next()
+			l.interp = append(l.interp, interpolation{pos: l.offsetPos(l.start)})
+			l.emit(itemInterpStart)
+			return lexCode
+		case '\\':
+			esc := l.pos - l.width
+			switch r := l.next(); r {
+			case eof:
+				return l.unclosedString()
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case '"', '\\':
+				sb.WriteRune(r)
+			case 'u':
+				if !l.unicodeEscape(esc, &sb) && !l.resume() {
+					return nil
+				}
+			case '$':
+				if !l.opts.Interpolation {
+					l.report(esc, ErrUnknownEscape, "unknown escape sequence: \\%c", r)
+					if !l.resume() {
+						return nil
+					}
+				}
+				sb.WriteRune(r)
+			default:
+				// In recovery mode, the rest of the string is
+				// still scanned.
+				l.report(esc, ErrUnknownEscape, "unknown escape sequence: \\%c", r)
+				if !l.resume() {
+					return nil
+				}
+			}
+		case '\n', '\r':
+			if l.opts.SingleLineStrings {
+				l.backup()
+				return l.errorf(ErrNewlineInString, "newline in string %s", preview(l.input[l.start:l.pos]))
+			}
+			if r == '\r' && l.opts.NormalizeNewlines {
+				l.accept('\n')
+				r = '\n'
+			}
+			sb.WriteRune(r)
+		default:
+			if l.invalidUTF8(r) {
+				pos := l.pos - l.width
+				l.report(pos, ErrInvalidUTF8, "invalid UTF-8 encoding: %#x", l.input[pos])
+				if !l.resume() {
+					return nil
+				}
+			}
+			sb.WriteRune(r)
+		}
+	}
+}
+
+// unicodeEscape scans the rest of the escape sequence \u{X...} that
+// starts at esc, where X... are one to six hexadecimal digits, and
+// writes the code point it denotes to sb. It returns false after
+// reporting an error if the escape sequence is invalid. If the input
+// ends within the escape sequence, it returns true, so the caller
+// reports the unclosed string.
+func (l *lexer) unicodeEscape(esc int, sb *strings.Builder) bool {
+	if l.peek() == eof {
+		return true
+	}
+	if !l.accept('{') {
+		return l.invalidEscape(esc, "missing '{' after \\u")
+	}
+	digits := l.pos
+	for {
+		r := l.next()
+		if r == eof {
+			return true
+		}
+		if r == '}' {
+			break
+		}
+		if !isHexDigit(r) {
+			l.backup()
+			return l.invalidEscape(esc, "invalid character %q in Unicode escape", r)
+		}
+	}
+
+	hex := l.input[digits : l.pos-1]
+	if hex == "" {
+		return l.invalidEscape(esc, "empty Unicode escape")
+	}
+	cp, err := strconv.ParseUint(hex, 16, 32)
+	switch {
+	case len(hex) > 6 || err != nil || cp > unicode.MaxRune:
+		return l.invalidEscape(esc, "Unicode escape \\u{%s} out of range", hex)
+	case 0xD800 <= cp && cp <= 0xDFFF:
+		return l.invalidEscape(esc, "Unicode escape \\u{%s} is a surrogate", hex)
+	}
+	sb.WriteRune(rune(cp))
+	return true
+}
+
+// invalidEscape reports an invalid escape sequence starting at esc.
+// It always returns false.
+func (l *lexer) invalidEscape(esc int, format string, args ...any) bool {
+	l.report(esc, ErrInvalidEscape, "invalid escape sequence: "+format, args...)
+	return false
+}
+
+// lexRawQuote scans a raw string, delimited by backticks. Escape
+// sequences and newlines are taken literally, so the literal value of
+// the emitted item is the text between the backticks.
+func lexRawQuote(l *lexer) stateFn {
+	l.capped = true
+	for {
+		switch l.next() {
+		case eof:
+			return l.unclosedString()
+		case '`':
+			lit := l.input[l.start+1 : l.pos-1]
+			l.checkHidden()
+			l.emitItem(item{typ: itemRawString, lit: lit})
+			return lexCode
+		}
 	}
 }
 
-// lexNumber scans a number.
+// previewLen is the maximum number of runes of a partial literal
+// quoted in error messages.
+const previewLen = 20
+
+// unclosedString reports a string that reaches the end of the input.
+// The error is positioned at the opening quote and quotes the first
+// line of the partial literal.
+func (l *lexer) unclosedString() stateFn {
+	return l.errorf(ErrUnclosedString, "unclosed string %s", preview(l.input[l.start:l.pos]))
+}
+
+// preview returns the first line of s, truncated to previewLen runes.
+func preview(s string) string {
+	trunc := false
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
+		trunc = true
+	}
+	if utf8.RuneCountInString(s) > previewLen {
+		s = string([]rune(s)[:previewLen])
+		trunc = true
+	}
+	if trunc {
+		s += "..."
+	}
+	return s
+}
+
+// lexNumber scans a number. Besides decimal numbers, optionally with
+// an exponent (2.5e-3), it accepts hexadecimal (0x1F) and binary
+// (0b1010) integers. Digits can be separated by underscores
+// (1_000_000). The literal value of the emitted item is the number
+// without separators.
 func lexNumber(l *lexer) stateFn {
-	l.acceptRun(unicode.IsDigit)
+	l.capped = true
+	zero := l.accept('0')
+	if zero {
+		switch {
+		case l.accept('x') || l.accept('X'):
+			return l.lexBasedNumber(16, "hexadecimal", isHexDigit)
+		case l.accept('b') || l.accept('B'):
+			return l.lexBasedNumber(2, "binary", isBinaryDigit)
+		}
+	}
 
-	if l.accept('.') {
-		l.acceptRun(unicode.IsDigit)
+	if !l.acceptDigits(unicode.IsDigit, zero) {
+		return l.misplacedSeparator()
 	}
 
-	l.emit(itemNumber)
+	// A dot not followed by a digit is not part of the number, as in
+	// the method call 1.foo().
+	if l.peek() == '.' && unicode.IsDigit(l.peekN(2)) {
+		l.next()
+		if !l.acceptDigits(unicode.IsDigit, false) {
+			return l.misplacedSeparator()
+		}
+	}
+
+	// An e followed by neither a digit nor a sign is not part of the
+	// number, as in 1ex, so the exponent is scanned speculatively.
+	m := l.mark()
+	if l.accept('e') || l.accept('E') {
+		signed := l.accept('+') || l.accept('-')
+		switch {
+		case unicode.IsDigit(l.next()):
+			if !l.acceptDigits(unicode.IsDigit, true) {
+				return l.misplacedSeparator()
+			}
+		case signed:
+			l.backup()
+			return l.errorf(ErrInvalidNumber, "exponent has no digits: %s", l.input[l.start:l.pos])
+		default:
+			l.reset(m)
+		}
+	}
+
+	l.emitNumber(10)
+	return lexCode
+}
+
+// lexBasedNumber scans the digits of a number in the given base once
+// its prefix has been consumed.
+func (l *lexer) lexBasedNumber(base int, name string, isDigit condFn) stateFn {
+	if !isDigit(l.next()) {
+		l.backup()
+		return l.errorf(ErrInvalidNumber, "malformed %s literal: %s", l.opts.Messages.translate(name), l.input[l.start:l.pos])
+	}
+	if !l.acceptDigits(isDigit, true) {
+		return l.misplacedSeparator()
+	}
+	if r := l.next(); isAlphaNumeric(r) {
+		return l.errorf(ErrInvalidNumber, "invalid digit %q in %s literal", r, l.opts.Messages.translate(name))
+	}
+	l.backup()
+	l.emitNumber(base)
 	return lexCode
 }
 
+// acceptDigits consumes a run of digits that meet isDigit, optionally
+// separated by single underscores. afterDigit tells whether the rune
+// preceding the run is a digit. It returns false if a separator is
+// leading, trailing or adjacent to another separator.
+func (l *lexer) acceptDigits(isDigit condFn, afterDigit bool) bool {
+	ok := true
+	for {
+		switch r := l.next(); {
+		case isDigit(r):
+			afterDigit = true
+		case r == '_':
+			if !afterDigit {
+				ok = false
+			}
+			afterDigit = false
+		default:
+			l.backup()
+			return ok && (afterDigit || l.input[l.pos-1] != '_')
+		}
+	}
+}
+
+// misplacedSeparator reports a misplaced digit separator.
+func (l *lexer) misplacedSeparator() stateFn {
+	return l.errorf(ErrInvalidNumber, "misplaced digit separator: %s", l.input[l.start:l.pos])
+}
+
+// emitNumber emits a number in the given base, whose literal value is
+// the number without digit separators.
+func (l *lexer) emitNumber(base int) {
+	lit := strings.ReplaceAll(l.input[l.start:l.pos], "_", "")
+	l.emitItem(item{typ: itemNumber, lit: lit, base: base})
+}
+
+// lowerASCII returns s with the ASCII letters mapped to lower case.
+// Other letters are left alone, so that keywords are only folded in
+// their ASCII spellings and identifiers such as İf, whose lower case
+// depends on the language, never match a Lox keyword.
+func lowerASCII(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool { return 'A' <= r && r <= 'Z' })
+	if i < 0 {
+		return s
+	}
+	b := []byte(s)
+	for ; i < len(b); i++ {
+		if 'A' <= b[i] && b[i] <= 'Z' {
+			b[i] += 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
 // lexIdentifier scans an identifier.
 func lexIdentifier(l *lexer) stateFn {
-	l.acceptRun(isAlphaNumeric)
+	l.capped = true
+	l.acceptRun(l.isIdentPart)
 
 	word := l.input[l.start:l.pos]
-	if kw, ok := key[word]; ok {
-		l.emit(kw)
+	folded := word
+	if l.opts.FoldKeywords {
+		folded = lowerASCII(word)
+	}
+	var (
+		kw itemType
+		ok bool
+	)
+	if l.opts.Keywords == nil {
+		kw, ok = keyword(folded)
 	} else {
-		l.emit(itemIdentifier)
+		kw, ok = l.opts.Keywords[folded]
+	}
+	if !ok {
+		kw = itemIdentifier
+	}
+	l.checkConfusable()
+	if l.opts.Intern != nil {
+		l.emitItem(item{typ: kw, lit: l.opts.Intern.Intern(word)})
+	} else {
+		l.emit(kw)
 	}
 	return lexCode
 }
 
+// invalidUTF8 returns whether r, the last rune returned by
+// [*lexer.next], comes from an invalid UTF-8 sequence.
+func (l *lexer) invalidUTF8(r rune) bool {
+	return r == utf8.RuneError && l.width == 1
+}
+
+// isIdentStart returns whether r can start an identifier.
+func (l *lexer) isIdentStart(r rune) bool {
+	if (l.opts.ASCIIIdents || l.opts.StrictASCII) && r >= utf8.RuneSelf {
+		return false
+	}
+	return isAlpha(r)
+}
+
+// isIdentPart returns whether r can be part of an identifier.
+func (l *lexer) isIdentPart(r rune) bool {
+	if (l.opts.ASCIIIdents || l.opts.StrictASCII) && r >= utf8.RuneSelf {
+		return false
+	}
+	return isAlphaNumeric(r)
+}
+
 // isAlpha returns whether r is a letter or underscore.
 func isAlpha(r rune) bool {
 	return unicode.IsLetter(r) || r == '_'
@@ -382,12 +1399,28 @@ func isAlphaNumeric(r rune) bool {
 	return isAlpha(r) || unicode.IsDigit(r)
 }
 
+// isHexDigit returns whether r is a hexadecimal digit.
+func isHexDigit(r rune) bool {
+	return ('0' <= r && r <= '9') || ('a' <= r && r <= 'f') || ('A' <= r && r <= 'F')
+}
+
+// isBinaryDigit returns whether r is a binary digit.
+func isBinaryDigit(r rune) bool {
+	return r == '0' || r == '1'
+}
+
 // isSpace returns whether r is a space character.
 func isSpace(r rune) bool {
 	return r == ' ' || r == '\r' || r == '\t' || r == '\n'
 }
 
-// isEOL returns whether r is a newline or eof.
+// isBlank returns whether r is a space character that does not end a
+// line.
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// isEOL returns whether r is a line terminator or eof.
 func isEOL(r rune) bool {
-	return r == '\n' || r == eof
+	return r == '\n' || r == '\r' || r == eof
 }
@@ -0,0 +1,922 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// benchProgram is a small Lox program used by the benchmarks.
+const benchProgram = `// Compute the first Fibonacci numbers.
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+
+var i = 0;
+while (i < 20) {
+  print "fib(" + i + ") = " + fib(i);
+  i = i + 1;
+}
+`
+
+// benchInputs are the inputs lexed by the benchmarks.
+var benchInputs = []struct {
+	name  string
+	input string
+}{
+	{"Small", benchProgram},
+	{"Large", strings.Repeat(benchProgram, 1000)},
+	{"Identifiers", strings.Repeat("foo bar baz qux quux corge grault garply ", 10000)},
+	{"Keywords", strings.Repeat("var fun class this super return while print ", 10000)},
+	{"Numbers", strings.Repeat("1 23 4.56 0x7F 0b101 1_000 2.5e-3 ", 10000)},
+	{"LongString", `"` + strings.Repeat("abcdefghij", 100000) + `"`},
+	{"LineComments", strings.Repeat("// comment\n", 50000)},
+	{"BlockComment", "/*" + strings.Repeat("* comment\n", 50000) + "*/"},
+	{"Whitespace", strings.Repeat(" \t\n", 100000)},
+}
+
+func BenchmarkLex(b *testing.B) {
+	for _, bi := range benchInputs {
+		b.Run(bi.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bi.input)))
+			b.ReportAllocs()
+			for range b.N {
+				for range Lex(bi.input) {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLexChan(b *testing.B) {
+	for _, bi := range benchInputs {
+		b.Run(bi.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bi.input)))
+			b.ReportAllocs()
+			for range b.N {
+				for range lex(bi.input) {
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLexReader(b *testing.B) {
+	for _, bi := range benchInputs {
+		b.Run(bi.name, func(b *testing.B) {
+			b.SetBytes(int64(len(bi.input)))
+			b.ReportAllocs()
+			for range b.N {
+				for range lexReader(strings.NewReader(bi.input)) {
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLexItems measures the memory used to keep all the items of
+// an input. The values of the items are not copied, so the bytes
+// allocated per operation are dominated by the size of item.
+func BenchmarkLexItems(b *testing.B) {
+	input := strings.Repeat(benchProgram, 100)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for range b.N {
+		var items []item
+		for it := range Lex(input) {
+			items = append(items, it)
+		}
+	}
+}
+
+func BenchmarkLexReaderItems(b *testing.B) {
+	input := strings.Repeat(benchProgram, 100)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for range b.N {
+		var items []item
+		for it := range lexReader(strings.NewReader(input)) {
+			items = append(items, it)
+		}
+	}
+}
+
+// keywordWords is a mix of keywords and identifiers used to compare
+// the ways of recognizing keywords.
+var keywordWords = strings.Fields(benchProgram + "and class else false for nil or super this true " +
+	"foo bar baz qux quux corge grault garply returns ifx a _ printer")
+
+// keywordSink keeps the compiler from optimizing away the keyword
+// lookups of BenchmarkKeyword.
+var keywordSink int
+
+func BenchmarkKeyword(b *testing.B) {
+	b.Run("Map", func(b *testing.B) {
+		for range b.N {
+			for _, w := range keywordWords {
+				if _, ok := key[w]; ok {
+					keywordSink++
+				}
+			}
+		}
+	})
+	b.Run("Switch", func(b *testing.B) {
+		for range b.N {
+			for _, w := range keywordWords {
+				if _, ok := keyword(w); ok {
+					keywordSink++
+				}
+			}
+		}
+	})
+}
+
+// TestKeyword checks that the generated function keyword agrees with
+// the key map.
+func TestKeyword(t *testing.T) {
+	for w, want := range key {
+		if got, ok := keyword(w); !ok || got != want {
+			t.Errorf("keyword(%q) = %v, %v; want %v, true", w, got, ok, want)
+		}
+	}
+	for _, w := range []string{"", "a", "o", "an", "andy", "Class", "IF", "fo", "forr", "th", "thiss", "returns", "whilee", "supe", "é", "niil"} {
+		if got, ok := keyword(w); ok {
+			t.Errorf("keyword(%q) = %v, true; want false", w, got)
+		}
+	}
+}
+
+// fuzzSeeds are the initial inputs of the fuzz targets.
+var fuzzSeeds = []string{
+	"",
+	benchProgram,
+	"class Foo < Bar { init(x) { this.x = x; super.init(); } }",
+	"var s = \"a\\tb\\n\\\"c\\\"\"; var r = `raw\\n`;",
+	"1 1.5 2. 0x1F 0b101 1_000 2.5e-3 1e 0x",
+	"/* block\n comment */ // line\r\n/// doc",
+	"#!/usr/bin/env loxi\nprint 1;",
+	"\uFEFFvar é = \"\xff\";",
+	"\"unclosed",
+	"/* unclosed",
+	"@ # $",
+}
+
+func FuzzLex(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		// In trivia mode, the items and their trivia cover the input
+		// up to the first error.
+		var (
+			sb   strings.Builder
+			last item
+		)
+		for it := range Lex(input, withTrivia()) {
+			last = it
+			if it.typ == itemError {
+				if it.start < sb.Len() {
+					t.Fatalf("error at %d overlaps covered input %q", it.start, sb.String())
+				}
+				break
+			}
+			for _, tr := range it.lead {
+				writeAt(t, &sb, tr.start, tr.val)
+			}
+			writeAt(t, &sb, it.start, it.Val())
+			for _, tr := range it.trail {
+				writeAt(t, &sb, tr.start, tr.val)
+			}
+		}
+		switch last.typ {
+		case itemError:
+		case itemEOF:
+			if sb.String() != input {
+				t.Fatalf("items cover %q, want %q", sb.String(), input)
+			}
+		default:
+			t.Fatalf("last item is %v, want EOF or error", last.typ)
+		}
+
+		// In recovery mode, the scan always reaches EOF.
+		n := 0
+		for it := range Lex(input, withRecovery()) {
+			if n++; n > 2*len(input)+2 {
+				t.Fatalf("too many items for %d bytes of input", len(input))
+			}
+			last = it
+		}
+		if last.typ != itemEOF {
+			t.Fatalf("last item is %v, want EOF", last.typ)
+		}
+	})
+}
+
+// writeAt appends s, which must start at offset off of the input, to
+// sb.
+func writeAt(t *testing.T, sb *strings.Builder, off int, s string) {
+	t.Helper()
+	if off != sb.Len() {
+		t.Fatalf("%q starts at %d, want %d", s, off, sb.Len())
+	}
+	sb.WriteString(s)
+}
+
+func TestLexNumberDot(t *testing.T) {
+	type tok struct {
+		typ itemType
+		val string
+	}
+	tests := []struct {
+		input string
+		want  []tok
+	}{
+		{"1.5", []tok{{itemNumber, "1.5"}, {itemEOF, ""}}},
+		{"1.", []tok{{itemNumber, "1"}, {itemDot, "."}, {itemEOF, ""}}},
+		{"1.foo", []tok{{itemNumber, "1"}, {itemDot, "."}, {itemIdentifier, "foo"}, {itemEOF, ""}}},
+		{"1..2", []tok{{itemNumber, "1"}, {itemDot, "."}, {itemDot, "."}, {itemNumber, "2"}, {itemEOF, ""}}},
+	}
+	for _, tt := range tests {
+		var got []tok
+		for it := range Lex(tt.input) {
+			got = append(got, tok{it.typ, it.Val()})
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLexContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	items := lexContext(ctx, strings.Repeat("a ", 10000))
+	if it := <-items; it.typ != itemIdentifier {
+		t.Fatalf("got %v, want identifier", it)
+	}
+	cancel()
+
+	// Once the context is done, the lexer stops sending items and
+	// closes the channel instead of blocking forever.
+	timeout := time.After(5 * time.Second)
+	n := 0
+	for {
+		select {
+		case _, ok := <-items:
+			if !ok {
+				if n >= 9999 {
+					t.Errorf("got %d items after cancellation, want fewer", n)
+				}
+				return
+			}
+			n++
+		case <-timeout:
+			t.Fatal("items channel not closed after cancellation")
+		}
+	}
+}
+
+func TestChanLexer(t *testing.T) {
+	const input = "var a = 1; # print a;"
+	want := lexDescs(input, withRecovery())
+
+	cl := StartLexer(input, withRecovery(), withChanSize(0))
+	var got []string
+	for range 2 {
+		got = append(got, itemDesc(<-cl.Items()))
+	}
+	for _, it := range cl.Drain() {
+		got = append(got, itemDesc(it))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if diags := cl.Diagnostics(); len(diags) != 1 || diags[0].Code != "LOX0002" {
+		t.Errorf("got diagnostics %v, want one LOX0002", diags)
+	}
+
+	// Stop after Drain, or twice, is safe, and Drain returns nothing
+	// once the items have been received.
+	cl.Stop()
+	cl.Stop()
+	if items := cl.Drain(); len(items) != 0 {
+		t.Errorf("Drain after Stop returned %d items, want 0", len(items))
+	}
+}
+
+func TestChanLexerStop(t *testing.T) {
+	cl := StartLexer(strings.Repeat("a ", 10000), withChanSize(0))
+	if it := <-cl.Items(); it.typ != itemIdentifier {
+		t.Fatalf("got %v, want identifier", it)
+	}
+	cl.Stop()
+	if _, ok := <-cl.Items(); ok {
+		t.Error("items channel not closed after Stop")
+	}
+	if items := cl.Drain(); len(items) != 0 {
+		t.Errorf("Drain after Stop returned %d items, want 0", len(items))
+	}
+}
+
+// itemDesc describes it for comparisons: its type and value, followed
+// by its literal value if it differs, or, for errors, the diagnostic
+// code and the position, such as Number "0x1F" "0x1F" or
+// Error LOX0004 1:3.
+func itemDesc(it item) string {
+	if it.typ == itemError {
+		var lerr *LexError
+		if !errors.As(it.err, &lerr) {
+			return fmt.Sprintf("Error %q", it.Val())
+		}
+		return fmt.Sprintf("Error %s %v", codes[lerr.Kind], lerr.Pos)
+	}
+	desc := fmt.Sprintf("%v %q", it.typ, it.Val())
+	if it.lit != "" && it.lit != it.Val() {
+		desc += fmt.Sprintf(" %q", it.lit)
+	}
+	return desc
+}
+
+// lexDescs returns the descriptions of the items of input lexed with
+// opts.
+func lexDescs(input string, opts ...option) []string {
+	var descs []string
+	for it := range Lex(input, opts...) {
+		descs = append(descs, itemDesc(it))
+	}
+	return descs
+}
+
+// lexTest is a test case of the lexer.
+type lexTest struct {
+	name  string
+	input string
+	want  []string // descriptions of the items, as returned by itemDesc.
+}
+
+// runLexTests checks the items of the inputs of tests lexed with opts.
+func runLexTests(t *testing.T, tests []lexTest, opts ...option) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lexDescs(tt.input, opts...); !slices.Equal(got, tt.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLex(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"Empty", "", []string{`EOF ""`}},
+		{"Punctuation", "(){},.-+;*/?:", []string{
+			`LeftParen "("`, `RightParen ")"`, `LeftBrace "{"`, `RightBrace "}"`,
+			`Comma ","`, `Dot "."`, `Minus "-"`, `Plus "+"`, `Semicolon ";"`,
+			`Star "*"`, `Slash "/"`, `Question "?"`, `Colon ":"`, `EOF ""`,
+		}},
+		{"Operators", "! != = == > >= < <=", []string{
+			`Bang "!"`, `BangEqual "!="`, `Equal "="`, `EqualEqual "=="`,
+			`Greater ">"`, `GreaterEqual ">="`, `Less "<"`, `LessEqual "<="`, `EOF ""`,
+		}},
+		{"Keywords", "var x = nil; fun classy", []string{
+			`Var "var"`, `Identifier "x"`, `Equal "="`, `Nil "nil"`, `Semicolon ";"`,
+			`Fun "fun"`, `Identifier "classy"`, `EOF ""`,
+		}},
+		{"Unicode", "var é_1 = 1;", []string{
+			`Var "var"`, `Identifier "é_1"`, `Equal "="`, `Number "1"`, `Semicolon ";"`, `EOF ""`,
+		}},
+		{"LineComment", "a // b\nc", []string{`Identifier "a"`, `Identifier "c"`, `EOF ""`}},
+		{"BlockComment", "a /* b\n * c */ d", []string{`Identifier "a"`, `Identifier "d"`, `EOF ""`}},
+		{"BlockCommentsDoNotNest", "/* /* */ */", []string{`Star "*"`, `Slash "/"`, `EOF ""`}},
+		{"UnclosedComment", "a\n  /* b", []string{`Identifier "a"`, `Error LOX0003 2:3`}},
+		{"String", `"hi"`, []string{`String "\"hi\"" "hi"`, `EOF ""`}},
+		{"EmptyString", `""`, []string{`String "\"\""`, `EOF ""`}},
+		{"MultiLineString", "\"a\nb\" c", []string{`String "\"a\nb\"" "a\nb"`, `Identifier "c"`, `EOF ""`}},
+		{"UnclosedString", "x = \"abc", []string{`Identifier "x"`, `Equal "="`, `Error LOX0001 1:5`}},
+		{"RawString", "`\\n\n`", []string{"RawString \"`\\\\n\\n`\" \"\\\\n\\n\"", `EOF ""`}},
+		{"UnclosedRawString", " `abc", []string{`Error LOX0001 1:2`}},
+		{"UnexpectedChar", "a @ b", []string{`Identifier "a"`, `Error LOX0002 1:3`}},
+		{"BOM", "\uFEFFa", []string{`Identifier "a"`, `EOF ""`}},
+		{"InvalidUTF8", "a \xff", []string{`Identifier "a"`, `Error LOX0008 1:3`}},
+		{"Shebang", "#!/usr/bin/env loxi\nprint 1;", []string{`Print "print"`, `Number "1"`, `Semicolon ";"`, `EOF ""`}},
+		{"HashNotShebang", "#x", []string{`Error LOX0002 1:1`}},
+	})
+}
+
+func TestLexEscapes(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"Valid", `"a\nb\tc\"d\\e"`, []string{`String "\"a\\nb\\tc\\\"d\\\\e\"" "a\nb\tc\"d\\e"`, `EOF ""`}},
+		{"QuoteDoesNotEndString", `"\"" x`, []string{`String "\"\\\"\"" "\""`, `Identifier "x"`, `EOF ""`}},
+		{"Unknown", `x "ab\qc"`, []string{`Identifier "x"`, `Error LOX0004 1:6`}},
+		{"UnknownDollar", `"\$"`, []string{`Error LOX0004 1:2`}},
+		{"UnknownOnSecondLine", "\"a\n  \\z\"", []string{`Error LOX0004 2:3`}},
+		{"TrailingBackslash", `x "ab\`, []string{`Identifier "x"`, `Error LOX0001 1:3`}},
+	})
+}
+
+func TestLexUnicodeEscapes(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"ASCII", `"\u{41}"`, []string{`String "\"\\u{41}\"" "A"`, `EOF ""`}},
+		{"TwoBytes", `"\u{e9}"`, []string{`String "\"\\u{e9}\"" "é"`, `EOF ""`}},
+		{"Astral", `"\u{1F600}"`, []string{`String "\"\\u{1F600}\"" "😀"`, `EOF ""`}},
+		{"MaxRune", `"\u{10FFFF}"`, []string{`String "\"\\u{10FFFF}\"" "\U0010ffff"`, `EOF ""`}},
+		{"LeadingZeros", `"\u{000041}"`, []string{`String "\"\\u{000041}\"" "A"`, `EOF ""`}},
+		{"Surrogate", `x "\u{D800}"`, []string{`Identifier "x"`, `Error LOX0011 1:4`}},
+		{"LastSurrogate", `"\u{dfff}"`, []string{`Error LOX0011 1:2`}},
+		{"OutOfRange", `"\u{110000}"`, []string{`Error LOX0011 1:2`}},
+		{"TooManyDigits", `"\u{0000041}"`, []string{`Error LOX0011 1:2`}},
+		{"Empty", `"\u{}"`, []string{`Error LOX0011 1:2`}},
+		{"MissingBrace", `"\u41"`, []string{`Error LOX0011 1:2`}},
+		{"InvalidChar", `"ab\u{4G}"`, []string{`Error LOX0011 1:4`}},
+		{"MissingClosingBrace", `"\u{41"`, []string{`Error LOX0011 1:2`}},
+		{"UnclosedAfterU", `"\u`, []string{`Error LOX0001 1:1`}},
+		{"UnclosedInDigits", `"\u{41`, []string{`Error LOX0001 1:1`}},
+		{"OnSecondLine", "\"a\n \\u{D800}\"", []string{`Error LOX0011 2:2`}},
+	})
+
+	// In recovery mode, the rest of the string is scanned.
+	runLexTests(t, []lexTest{
+		{"Recovery", `"a\u{}b\u{D800}c\qd" x`, []string{
+			`Error LOX0011 1:3`,
+			`Error LOX0011 1:8`,
+			`Error LOX0004 1:17`,
+			`String "\"a\\u{}b\\u{D800}c\\qd\"" "abcd"`,
+			`Identifier "x"`,
+			`EOF ""`,
+		}},
+	}, withRecovery())
+}
+
+// itemSpan describes the position and the span of it, such as
+// 2:3 [4,6).
+func itemSpan(it item) string {
+	return fmt.Sprintf("%d:%d [%d,%d)", it.line, it.col, it.start, it.end)
+}
+
+func TestLexPositions(t *testing.T) {
+	// The e of 1e is rewound, with the newline after it.
+	input := "a\n  bc \"x\ny\" /* z\n */ d\n1e\n2"
+	want := []string{"1:1 [0,1)", "2:3 [4,6)", "2:6 [7,12)", "4:5 [22,23)", "5:1 [24,25)", "5:2 [25,26)", "6:1 [27,28)", "6:2 [28,28)"}
+	var got []string
+	for it := range Lex(input) {
+		got = append(got, itemSpan(it))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLexReader(t *testing.T) {
+	// The items of a stream, even if it is read one byte at a time,
+	// are those of the same input as a string.
+	inputs := append(slices.Clone(fuzzSeeds), strings.Repeat("var x = \"é\";\n", readChunk/4))
+	for _, input := range inputs {
+		var want []string
+		for it := range Lex(input) {
+			want = append(want, itemDesc(it)+" "+itemSpan(it))
+		}
+		var got []string
+		for it := range lexReader(iotest.OneByteReader(strings.NewReader(input))) {
+			got = append(got, itemDesc(it)+" "+itemSpan(it))
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%.20q: got:\n%s\nwant:\n%s", input, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestLexReadError(t *testing.T) {
+	errBoom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader("a b"), iotest.ErrReader(errBoom))
+	var last item
+	for it := range lexReader(r) {
+		last = it
+	}
+	if last.typ != itemError || !errors.Is(last.err, ErrRead) || !errors.Is(last.err, errBoom) {
+		t.Errorf("got last item %v (%v), want read error", last, last.err)
+	}
+}
+
+func TestLexerNext(t *testing.T) {
+	input := "print 1 + 2;"
+	var want []item
+	for it := range Lex(input) {
+		want = append(want, it)
+	}
+	lx := NewLexer(input)
+	for i, w := range want {
+		if got := lx.Next(); itemDesc(got) != itemDesc(w) || itemSpan(got) != itemSpan(w) {
+			t.Errorf("item %d: got %s, want %s", i, itemDesc(got), itemDesc(w))
+		}
+	}
+	// Next keeps returning the last item.
+	if got := lx.Next(); got.typ != itemEOF {
+		t.Errorf("after EOF, got %v, want EOF", got)
+	}
+}
+
+func TestLexRecovery(t *testing.T) {
+	input := "a @ \"b\\q\" #\n\"c"
+	runLexTests(t, []lexTest{
+		{"Recovery", input, []string{
+			`Identifier "a"`, `Error LOX0002 1:3`, `Error LOX0004 1:7`,
+			`String "\"b\\q\"" "b"`, `Error LOX0002 1:11`, `Error LOX0001 2:1`, `EOF ""`,
+		}},
+	}, withRecovery())
+	runLexTests(t, []lexTest{
+		{"MaxErrors", input, []string{`Identifier "a"`, `Error LOX0002 1:3`, `Error LOX0004 1:7`}},
+	}, withRecovery(), withMaxErrors(2))
+
+	// The errors are also reported as diagnostics.
+	lx := NewLexer(input, withRecovery())
+	for lx.Next().typ != itemEOF {
+	}
+	var got []string
+	for _, d := range lx.Diagnostics() {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"1:3: error[LOX0002]: unexpected character: @",
+		"1:7: error[LOX0004]: unknown escape sequence: \\q",
+		"1:11: error[LOX0002]: unexpected character: #",
+		"2:1: error[LOX0001]: unclosed string \"c",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLexErrorKinds(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  error
+	}{
+		{`"abc`, ErrUnclosedString},
+		{"@", ErrUnexpectedChar},
+		{"/*", ErrUnclosedComment},
+		{`"\q"`, ErrUnknownEscape},
+		{"0x", ErrInvalidNumber},
+		{"\xff", ErrInvalidUTF8},
+	}
+	for _, tt := range tests {
+		var last item
+		for it := range Lex(tt.input) {
+			last = it
+		}
+		var lerr *LexError
+		if !errors.As(last.err, &lerr) || !errors.Is(last.err, tt.kind) {
+			t.Errorf("%q: got error %v, want %v", tt.input, last.err, tt.kind)
+		}
+	}
+}
+
+func TestLexComments(t *testing.T) {
+	input := "a // b\n/* c */ d /// e"
+	runLexTests(t, []lexTest{
+		{"Comments", input, []string{
+			`Identifier "a"`, `Comment "// b"`, `Comment "/* c */"`, `Identifier "d"`, `DocComment "/// e"`, `EOF ""`,
+		}},
+	}, withComments())
+}
+
+func TestLexTrivia(t *testing.T) {
+	// Trivia up to the end of the line of an item is attached to it,
+	// and the rest to the next item.
+	input := "\uFEFF#!/bin/lox\n  a // b\n\n/* c */ d"
+	want := []string{
+		`lead ["\ufeff" "#!/bin/lox" "\n" "  "] Identifier "a" trail [" " "// b" "\n"]`,
+		`lead ["\n" "/* c */" " "] Identifier "d" trail []`,
+		`lead [] EOF "" trail []`,
+	}
+	var got []string
+	for it := range Lex(input, withTrivia()) {
+		var lead, trail []string
+		for _, tr := range it.lead {
+			lead = append(lead, strconv.Quote(tr.val))
+		}
+		for _, tr := range it.trail {
+			trail = append(trail, strconv.Quote(tr.val))
+		}
+		got = append(got, fmt.Sprintf("lead [%s] %s trail [%s]", strings.Join(lead, " "), itemDesc(it), strings.Join(trail, " ")))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLexNumbers(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"Integer", "123", []string{`Number "123"`, `EOF ""`}},
+		{"Decimal", "0.5", []string{`Number "0.5"`, `EOF ""`}},
+		{"Hexadecimal", "0x1F 0XaB", []string{`Number "0x1F"`, `Number "0XaB"`, `EOF ""`}},
+		{"Binary", "0b1010 0B1", []string{`Number "0b1010"`, `Number "0B1"`, `EOF ""`}},
+		{"NoOctal", "0o17", []string{`Number "0"`, `Identifier "o17"`, `EOF ""`}},
+		{"Exponent", "1e10 2.5E-3 7e+2", []string{`Number "1e10"`, `Number "2.5E-3"`, `Number "7e+2"`, `EOF ""`}},
+		{"Separators", "1_000_000 0xF_F 0b1_0 1_0.2_5e1_0", []string{
+			`Number "1_000_000" "1000000"`, `Number "0xF_F" "0xFF"`, `Number "0b1_0" "0b10"`,
+			`Number "1_0.2_5e1_0" "10.25e10"`, `EOF ""`,
+		}},
+		{"LeadingSeparator", "_1", []string{`Identifier "_1"`, `EOF ""`}},
+
+		{"EmptyHexadecimal", "0x", []string{`Error LOX0005 1:1`}},
+		{"EmptyBinary", "a 0b;", []string{`Identifier "a"`, `Error LOX0005 1:3`}},
+		{"InvalidHexadecimalDigit", "0x1G", []string{`Error LOX0005 1:1`}},
+		{"InvalidBinaryDigit", "0b102", []string{`Error LOX0005 1:1`}},
+		{"SignedExponentWithoutDigits", "1e-x", []string{`Error LOX0005 1:1`}},
+		{"SignOnlyExponent", "1E+", []string{`Error LOX0005 1:1`}},
+		{"AdjacentSeparators", "1__2", []string{`Error LOX0005 1:1`}},
+		{"TrailingSeparator", "1_", []string{`Error LOX0005 1:1`}},
+		{"SeparatorBeforeDot", "1_.5", []string{`Error LOX0005 1:1`}},
+		{"SeparatorAfterDot", "1._5", []string{`Number "1"`, `Dot "."`, `Identifier "_5"`, `EOF ""`}},
+		{"SeparatorAfterExponent", "1e_5", []string{`Number "1"`, `Identifier "e_5"`, `EOF ""`}},
+
+		// An e that does not start an exponent is rewound.
+		{"ExponentWithoutDigits", "1e", []string{`Number "1"`, `Identifier "e"`, `EOF ""`}},
+		{"IdentifierAfterNumber", "1ex 2.5Ex", []string{`Number "1"`, `Identifier "ex"`, `Number "2.5"`, `Identifier "Ex"`, `EOF ""`}},
+		{"ExponentAtEndOfLine", "1e\n2", []string{`Number "1"`, `Identifier "e"`, `Number "2"`, `EOF ""`}},
+		{"SeparatorAfterPrefix", "0x_FF", []string{`Error LOX0005 1:1`}},
+	})
+}
+
+func TestLexKeywordOptions(t *testing.T) {
+	input := "var let break print"
+	tests := []struct {
+		name string
+		opts []option
+		want []string
+	}{
+		{"Default", nil, []string{`Var "var"`, `Identifier "let"`, `Identifier "break"`, `Print "print"`}},
+		{"Table", []option{withKeywordTable(map[string]itemType{"let": itemVar})}, []string{
+			`Identifier "var"`, `Var "let"`, `Identifier "break"`, `Identifier "print"`,
+		}},
+		{"Add", []option{withKeywords("break", "let")}, []string{
+			`Var "var"`, `Keyword "let"`, `Keyword "break"`, `Print "print"`,
+		}},
+		{"Remove", []option{withoutKeywords("print", "var")}, []string{
+			`Identifier "var"`, `Identifier "let"`, `Identifier "break"`, `Identifier "print"`,
+		}},
+		{"AddToTable", []option{withKeywordTable(map[string]itemType{"let": itemVar}), withKeywords("break")}, []string{
+			`Identifier "var"`, `Var "let"`, `Keyword "break"`, `Identifier "print"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for it := range Lex(input, tt.opts...) {
+				if it.typ != itemEOF {
+					got = append(got, itemDesc(it))
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The table of the options is not modified.
+	table := map[string]itemType{"let": itemVar}
+	for range Lex(input, withKeywordTable(table), withKeywords("break"), withoutKeywords("let")) {
+	}
+	if len(table) != 1 || table["let"] != itemVar {
+		t.Errorf("keyword table modified: %v", table)
+	}
+}
+
+func TestLexFoldKeywords(t *testing.T) {
+	const input = "while WHILE While wHiLe whiles"
+	runLexTests(t, []lexTest{
+		{"Default", input, []string{
+			`While "while"`, `Identifier "WHILE"`, `Identifier "While"`, `Identifier "wHiLe"`, `Identifier "whiles"`, `EOF ""`,
+		}},
+	})
+	runLexTests(t, []lexTest{
+		{"Fold", input, []string{
+			`While "while"`, `While "WHILE"`, `While "While"`, `While "wHiLe"`, `Identifier "whiles"`, `EOF ""`,
+		}},
+	}, withFoldKeywords())
+	runLexTests(t, []lexTest{
+		{"Table", "LET Var", []string{`Var "LET"`, `Identifier "Var"`, `EOF ""`}},
+	}, withFoldKeywords(), withKeywordTable(map[string]itemType{"Let": itemVar}))
+	runLexTests(t, []lexTest{
+		// Only ASCII letters are folded, so the dotted capital I and
+		// the dotless i do not match the i of if.
+		{"NonASCII", "\u0130F \u0131f \u0130f", []string{
+			"Identifier \"\u0130F\"", "Identifier \"\u0131f\"", "Identifier \"\u0130f\"", `EOF ""`,
+		}},
+	}, withFoldKeywords())
+	runLexTests(t, []lexTest{
+		{"Interned", "PRINT print", []string{`Print "PRINT"`, `Print "print"`, `EOF ""`}},
+	}, withFoldKeywords(), withInterning(NewInterner()))
+}
+
+func TestLexASCII(t *testing.T) {
+	t.Run("Idents", func(t *testing.T) {
+		runLexTests(t, []lexTest{
+			{"ASCII", "a_1", []string{`Identifier "a_1"`, `EOF ""`}},
+			{"Start", "x = é;", []string{`Identifier "x"`, `Equal "="`, `Error LOX0002 1:5`}},
+			{"Part", "\naé", []string{`Identifier "a"`, `Error LOX0002 2:2`}},
+			{"String", `"é" // é`, []string{`String "\"é\"" "é"`, `EOF ""`}},
+		}, withASCIIIdents())
+	})
+	t.Run("Strict", func(t *testing.T) {
+		runLexTests(t, []lexTest{
+			{"Start", "x = é;", []string{`Identifier "x"`, `Equal "="`, `Error LOX0007 1:5`}},
+			{"Part", "\naé", []string{`Identifier "a"`, `Error LOX0007 2:2`}},
+			{"Symbol", "1 ≠ 2", []string{`Number "1"`, `Error LOX0007 1:3`}},
+			{"String", `"é" // é`, []string{`String "\"é\"" "é"`, `EOF ""`}},
+			{"BlockComment", "/* é */ a", []string{`Identifier "a"`, `EOF ""`}},
+		}, withStrictASCII())
+	})
+}
+
+func TestLexNewlines(t *testing.T) {
+	// Apart from their offsets, the items of input with CRLF or lone
+	// CR line terminators are those of input with LF ones.
+	lf := "var s = \"a\n\nb\";\n// c\n  print s;\n\n@"
+	desc := func(input string) []string {
+		var descs []string
+		for it := range Lex(input, withNormalizeNewlines(), withRecovery()) {
+			d := fmt.Sprintf("%d:%d %v %q", it.line, it.col, it.typ, it.lit)
+			if it.typ != itemString {
+				d += " " + strconv.Quote(it.Val())
+			}
+			descs = append(descs, d)
+		}
+		return descs
+	}
+	want := desc(lf)
+	for _, nl := range []string{"\r\n", "\r"} {
+		input := strings.ReplaceAll(lf, "\n", nl)
+		if got := desc(input); !slices.Equal(got, want) {
+			t.Errorf("%q: got:\n%s\nwant:\n%s", nl, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+	if !slices.Contains(want, `1:9 String "a\n\nb"`) || !slices.Contains(want, `7:1 Error "unexpected character: @" "unexpected character: @"`) {
+		t.Errorf("unexpected items of %q:\n%s", lf, strings.Join(want, "\n"))
+	}
+
+	// Without the option, the literal values keep their terminators.
+	for it := range Lex("\"a\r\nb\"") {
+		if it.typ == itemString && it.lit != "a\r\nb" {
+			t.Errorf("got literal %q, want %q", it.lit, "a\r\nb")
+		}
+	}
+}
+
+func TestLexSingleLineStrings(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"LF", "x \"ab\ncd\"", []string{`Identifier "x"`, `Error LOX0012 1:3`}},
+		{"CRLF", "\"a\r\nb\"", []string{`Error LOX0012 1:1`}},
+		{"CR", "\"a\rb\"", []string{`Error LOX0012 1:1`}},
+		{"SecondLine", "x;\n  \"a\nb\"", []string{`Identifier "x"`, `Semicolon ";"`, `Error LOX0012 2:3`}},
+		{"Escape", `"a\nb"`, []string{`String "\"a\\nb\"" "a\nb"`, `EOF ""`}},
+		{"RawString", "`a\nb`", []string{"RawString \"`a\\nb`\" \"a\\nb\"", `EOF ""`}},
+	}, withSingleLineStrings())
+
+	// The error spans the string up to the end of the line.
+	for it := range Lex("x \"ab\ncd\"", withSingleLineStrings()) {
+		if it.typ == itemError {
+			if got, want := itemSpan(it), "1:3 [2,5)"; got != want {
+				t.Errorf("got error span %s, want %s", got, want)
+			}
+		}
+	}
+}
+
+func TestLexMaxTokenLen(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"Comment", "// a long comment\nx", []string{`Identifier "x"`, `EOF ""`}},
+		{"BlockComment", "/* a long comment */ x", []string{`Identifier "x"`, `EOF ""`}},
+		{"Whitespace", "x          y", []string{`Identifier "x"`, `Identifier "y"`, `EOF ""`}},
+		{"Identifier", "abcd abcde", []string{`Identifier "abcd"`, `Error LOX0010 1:6`}},
+		{"String", `"ab" "abc"`, []string{`String "\"ab\"" "ab"`, `Error LOX0010 1:6`}},
+		{"RawString", "`abc`", []string{`Error LOX0010 1:1`}},
+		{"Number", "1234 1.234", []string{`Number "1234"`, `Error LOX0010 1:6`}},
+	}, withMaxTokenLen(4))
+}
+
+func TestLexInterpolation(t *testing.T) {
+	runLexTests(t, []lexTest{
+		{"Plain", `"a"`, []string{`String "\"a\"" "a"`, `EOF ""`}},
+		{"Simple", `"a${b}c"`, []string{
+			`StringPart "\"a" "a"`, `InterpStart "${"`, `Identifier "b"`, `InterpEnd "}"`,
+			`StringPart "c\"" "c"`, `EOF ""`,
+		}},
+		{"Braces", `"${ {x} }"`, []string{
+			`StringPart "\""`, `InterpStart "${"`, `LeftBrace "{"`, `Identifier "x"`, `RightBrace "}"`,
+			`InterpEnd "}"`, `StringPart "\""`, `EOF ""`,
+		}},
+		{"Nested", `"a${"b${c}"}d"`, []string{
+			`StringPart "\"a" "a"`, `InterpStart "${"`,
+			`StringPart "\"b" "b"`, `InterpStart "${"`, `Identifier "c"`, `InterpEnd "}"`, `StringPart "\""`,
+			`InterpEnd "}"`, `StringPart "d\"" "d"`, `EOF ""`,
+		}},
+		{"EscapedDollar", `"\${a}"`, []string{`String "\"\\${a}\"" "${a}"`, `EOF ""`}},
+		{"Dollar", `"$a"`, []string{`String "\"$a\"" "$a"`, `EOF ""`}},
+		{"Unclosed", "x = \"a${\n  b", []string{
+			`Identifier "x"`, `Equal "="`, `StringPart "\"a" "a"`, `InterpStart "${"`, `Identifier "b"`,
+			`Error LOX0001 1:7`,
+		}},
+		{"UnclosedNested", `"a${"b${c`, []string{
+			`StringPart "\"a" "a"`, `InterpStart "${"`, `StringPart "\"b" "b"`, `InterpStart "${"`,
+			`Identifier "c"`, `Error LOX0001 1:7`,
+		}},
+		{"UnclosedOuter", `"a${"b${c}"`, []string{
+			`StringPart "\"a" "a"`, `InterpStart "${"`, `StringPart "\"b" "b"`, `InterpStart "${"`,
+			`Identifier "c"`, `InterpEnd "}"`, `StringPart "\""`, `Error LOX0001 1:3`,
+		}},
+	}, withInterpolation())
+	runLexTests(t, []lexTest{
+		{"Recovery", `"${a`, []string{
+			`StringPart "\""`, `InterpStart "${"`, `Identifier "a"`, `Error LOX0001 1:2`, `EOF ""`,
+		}},
+	}, withInterpolation(), withRecovery())
+}
+
+func TestLexDocComments(t *testing.T) {
+	input := "// a\n/// b\n//// c\n///\nfun f() {} /// d"
+	runLexTests(t, []lexTest{
+		{"DocComments", input, []string{
+			`DocComment "/// b"`, `DocComment "///"`, `Fun "fun"`, `Identifier "f"`,
+			`LeftParen "("`, `RightParen ")"`, `LeftBrace "{"`, `RightBrace "}"`,
+			`DocComment "/// d"`, `EOF ""`,
+		}},
+	}, withDocComments())
+
+	// In trivia mode, a doc comment that is not emitted is attached to
+	// the item that follows it as leading trivia, and, when it is
+	// emitted, the trivia that precedes it is attached to it.
+	tests := []struct {
+		name string
+		opts []option
+		want []string
+	}{
+		{"Trivia", []option{withTrivia()}, []string{
+			`["// a" "\n" "/// b" "\n"] Fun "fun"`,
+		}},
+		{"TriviaDocComments", []option{withTrivia(), withDocComments()}, []string{
+			`["// a" "\n"] DocComment "/// b"`,
+			`[] Fun "fun"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for it := range Lex("// a\n/// b\nfun", tt.opts...) {
+				if it.typ == itemEOF {
+					break
+				}
+				var lead []string
+				for _, tr := range it.lead {
+					lead = append(lead, strconv.Quote(tr.val))
+				}
+				got = append(got, fmt.Sprintf("[%s] %s", strings.Join(lead, " "), itemDesc(it)))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLexMetrics(t *testing.T) {
+	const input = "var a = \"x\"; // c\nprint a + 1.5;"
+	var m Metrics
+	for range 2 {
+		for range Lex(input, withMetrics(&m)) {
+		}
+	}
+
+	// The counters of both scans add up.
+	wantItems := map[itemType]int{
+		itemVar: 2, itemIdentifier: 4, itemEqual: 2, itemString: 2, itemSemicolon: 4,
+		itemPrint: 2, itemPlus: 2, itemNumber: 2, itemEOF: 2,
+	}
+	if !maps.Equal(m.Items, wantItems) {
+		t.Errorf("got items %v, want %v", m.Items, wantItems)
+	}
+	if want := 2 * len(input); m.Bytes != want {
+		t.Errorf("got %d bytes, want %d", m.Bytes, want)
+	}
+	calls := make(map[string]int)
+	for name, sm := range m.States {
+		calls[name] = sm.Calls
+		if sm.Time < 0 {
+			t.Errorf("%s: negative time %v", name, sm.Time)
+		}
+	}
+	wantCalls := map[string]int{
+		"lexStart": 2, "lexCode": 40, "lexIdentifier": 8, "lexQuote": 2, "lexNumber": 2, "lexComment": 2,
+	}
+	if !maps.Equal(calls, wantCalls) {
+		t.Errorf("got calls %v, want %v", calls, wantCalls)
+	}
+}
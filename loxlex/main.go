@@ -7,23 +7,535 @@
 // This implementation of the lexer is based on the also amazing talk
 // [Lexical Scanning in Go] by Rob Pike.
 //
+// Usage:
+//
+//	loxlex [flags] [file ...]
+//	loxlex cat [flags] [file ...]
+//	loxlex completion bash|fish|zsh
+//	loxlex diff [flags] file1 file2
+//	loxlex explain [code ...]
+//	loxlex explore [flags] file
+//	loxlex parse [flags] [file ...]
+//
+// Loxlex prints the tokens of the named files, or of the standard
+// input if there are none. The file name "-" also means the standard
+// input, and names starting with http:// or https:// are fetched from
+// the web. When there are several files, the tokens of each one are
+// preceded by its name.
+//
+// A file argument of the form dir/... stands for all the .lox files
+// in the directory tree rooted at dir, as does a directory argument
+// when the -r flag is given. In that case, the lexical errors found in
+// all the files are summarized at the end.
+//
+// Lexical errors are also reported on the standard error, prefixed
+// with the name of the file. The exit status is 1 if any lexical error
+// is found or a file cannot be read, and 2 if the flags are invalid or
+// the arguments cannot be expanded, in which case no file is lexed.
+//
+// The cat subcommand prints the files highlighted according to their
+// tokens, like bat, with line numbers and the lexical errors
+// underlined. Run "loxlex cat -h" for its flags.
+//
+// The diff subcommand compares the tokens of two files, ignoring
+// whitespace and comments, and prints the first difference. With the
+// -ast flag, it compares their syntax trees instead, so that
+// refactorings can be checked to preserve the structure of the
+// program, and prints every node removed, added or changed. It exits
+// with status 0 if there are no differences, 1 if there are, and 2 on
+// errors.
+//
+// Diagnostics have stable codes, such as LOX0001. The explain
+// subcommand prints the extended description of the given codes, with
+// examples, or the list of codes if none is given.
+//
+// The explore subcommand shows the source code of a file and its
+// tokens side by side in the terminal. The token selected with the
+// arrow keys, or j and k, is highlighted in the source code. Type q to
+// quit.
+//
+// The parse subcommand parses the files with the full grammar of Lox
+// and prints their syntax trees. The -format flag selects how: tree
+// (default) indents the nodes and shows their positions, sexpr writes
+// Lisp-style S-expressions such as (* (- 123) (group 45.67)), json
+// writes JSON objects with the kind, the span and the children of
+// every node, and dot writes Graphviz graphs, which can be rendered
+// with "loxlex parse -format dot file.lox | dot -Tsvg". For loops are
+// desugared into while loops unless the -keepfor flag is given, and
+// the -fold flag folds constant expressions, such as 1 + 2 * 3 into 7.
+// The -resolve flag resolves the local variables, whose depths are
+// shown in the tree and JSON formats, and reports the errors found by
+// the resolver, as well as warnings about unused local variables and
+// functions and about unreachable code. The errors and warnings are
+// reported on the standard error, with the source lines they refer to.
+//
+// The completion subcommand prints a completion script for the given
+// shell, which completes the subcommands, the flags and their values,
+// such as the token types of -only and -exclude. For instance, in
+// bash:
+//
+//	source <(loxlex completion bash)
+//
+// The flags are:
+//
+//	-bench
+//		lex every file repeatedly, as set by -benchtime, and print
+//		the throughput of the lexer in MB/s and tokens/s instead of
+//		the tokens.
+//	-benchtime t
+//		run each benchmark for t, which is a duration such as 1s
+//		(default) or a number of runs such as 100x.
+//	-color when
+//		colorize the table output by token category: auto
+//		(default), always or never. In auto mode, the output is
+//		colorized if it is a terminal and the NO_COLOR environment
+//		variable is not set.
+//	-cpuprofile file
+//		write a CPU profile of the scan of the files to file, to
+//		be examined with go tool pprof.
+//	-format format
+//		output format: table (default), json, ndjson, csv, html,
+//		sarif, binary or proto. The binary and proto formats
+//		support a single input. The html format prints the source
+//		code with its tokens wrapped in span elements, whose
+//		classes are the category and the type of the token,
+//		preceded by a default stylesheet. The sarif format prints
+//		the diagnostics instead of the tokens, for code scanning
+//		tools. When the standard input is a pipe, the ndjson
+//		format writes every token as soon as it is scanned, so
+//		loxlex can be a stage of a pipeline.
+//	-hexdump
+//		print a hex dump of the bytes of every token after it, and
+//		of any text between the tokens other than whitespace, such
+//		as a byte order mark, to find unexpected characters.
+//	-histogram
+//		print the number of tokens of every type, and of the most
+//		frequent identifiers, ranked by frequency with bar charts,
+//		instead of the tokens.
+//	-lang language
+//		print the diagnostic messages in the given language, such
+//		as es for Spanish, instead of the one selected by the
+//		LC_ALL, LC_MESSAGES and LANG environment variables. The
+//		default is English.
+//	-max-errors n
+//		report up to n lexical errors per file, resuming the scan
+//		after each one, instead of stopping at the first one.
+//		Zero means no limit.
+//	-memprofile file
+//		write a memory profile to file after the scan of the
+//		files, to be examined with go tool pprof.
+//	-only types
+//		print only the tokens of the given comma-separated types,
+//		such as Identifier,String.
+//	-exclude types
+//		do not print the tokens of the given comma-separated types.
+//	-positions
+//		prefix the rows of the table output with file:line:col, as
+//		expected by the quickfix lists of editors.
+//	-quiet
+//		print only the diagnostics, not the tokens, to check the
+//		files quickly, for instance when they are saved in an
+//		editor.
+//	-repl
+//		start an interactive session that prints the tokens of
+//		every line typed. The lines are kept in a history that
+//		can be recalled with !! and !N. Type :help for details.
+//	-r
+//		lex the .lox files in directory arguments recursively.
+//	-stats
+//		print statistics about the tokens, such as the number of
+//		tokens of every type, instead of the tokens. The bytes,
+//		lines and throughput of files whose scan stops at an
+//		error, as set by -max-errors, cover only the text scanned.
+//	-template text
+//		print every token, followed by a newline, with the given
+//		text/template template, such as
+//		'{{.Line}}:{{.Col}} {{.Type}} {{.Val}}'. The fields are
+//		File, Type, Category, Val, Lit, Line, Col, Start and End,
+//		and the quote function quotes strings as Go does.
+//	-watch
+//		lex the files again, clearing the screen first, every time
+//		any of them changes, until loxlex is interrupted.
+//
 // [Crafting Interpreters]: https://craftinginterpreters.com/
 // [Lexical Scanning in Go]: https://youtu.be/HxaD_trXwRE
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	bench      = flag.Bool("bench", false, "print the throughput of the lexer instead of the tokens")
+	benchTime  = flag.String("benchtime", "1s", "lex each file for `t`, a duration such as 1s or a number of runs such as 100x")
+	color      = flag.String("color", "auto", "colorize the table output `when`: auto, always or never")
+	cpuProfile = flag.String("cpuprofile", "", "write a CPU profile to `file`")
+	format     = flag.String("format", "table", "output `format`: table, json, ndjson, csv, html, sarif, binary or proto")
+	hexdump    = flag.Bool("hexdump", false, "print a hex dump of the bytes of every token")
+	histogram  = flag.Bool("histogram", false, "print the frequency of the token types and identifiers instead of the tokens")
+	lang       = flag.String("lang", "", "print the diagnostics in the given `language`, such as es")
+	maxErrors  = flag.Int("max-errors", 1, "report up to `n` lexical errors per file; 0 means no limit")
+	memProfile = flag.String("memprofile", "", "write a memory profile to `file`")
+	only       = flag.String("only", "", "print only the tokens of the comma-separated `types`")
+	exclude    = flag.String("exclude", "", "do not print the tokens of the comma-separated `types`")
+	positions  = flag.Bool("positions", false, "prefix the rows of the table output with file:line:col")
+	quiet      = flag.Bool("quiet", false, "print only the diagnostics, not the tokens")
+	replMode   = flag.Bool("repl", false, "lex the lines typed interactively")
+	recursive  = flag.Bool("r", false, "lex the .lox files in directory arguments recursively")
+	stats      = flag.Bool("stats", false, "print statistics about the tokens instead of the tokens")
+	tmpl       = flag.String("template", "", "print every token with the text/template `text`, such as '{{.Line}}:{{.Col}} {{.Type}} {{.Val}}'")
+	watch      = flag.Bool("watch", false, "lex the files again every time they change")
 )
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: loxlex [flags] [file ...]\n")
+	fmt.Fprintf(os.Stderr, "       loxlex cat [flags] [file ...]\n")
+	fmt.Fprintf(os.Stderr, "       loxlex completion bash|fish|zsh\n")
+	fmt.Fprintf(os.Stderr, "       loxlex diff [flags] file1 file2\n")
+	fmt.Fprintf(os.Stderr, "       loxlex explain [code ...]\n")
+	fmt.Fprintf(os.Stderr, "       loxlex explore [flags] file\n")
+	fmt.Fprintf(os.Stderr, "       loxlex parse [flags] [file ...]\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nThe exit status is 1 if any lexical error is found or a file cannot be\n")
+	fmt.Fprintf(os.Stderr, "read, and 2 if the flags are invalid or the arguments cannot be expanded.\n")
+	os.Exit(2)
+}
+
+// messages is the catalog of the diagnostic messages, selected by the
+// environment or the -lang flag.
+var messages Catalog
+
 func main() {
-	input, err := io.ReadAll(os.Stdin)
+	// The environment cannot select unsupported languages.
+	messages, _ = messageCatalog("")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "cat":
+			os.Exit(catMain(os.Args[2:]))
+		case "completion":
+			os.Exit(completionMain(os.Args[2:]))
+		case "diff":
+			os.Exit(diffMain(os.Args[2:]))
+		case "explain":
+			os.Exit(explainMain(os.Args[2:]))
+		case "explore":
+			os.Exit(exploreMain(os.Args[2:]))
+		case "parse":
+			os.Exit(parseMain(os.Args[2:]))
+		}
+	}
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *lang != "" {
+		var err error
+		messages, err = messageCatalog(*lang)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if *replMode {
+		os.Exit(repl())
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	if *watch {
+		watchArgs(args)
+	}
+
+	stop, err := startProfiling(*cpuProfile, *memProfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	var status int
+	if *bench {
+		status = benchArgs(args)
+	} else {
+		status = lexArgs(args)
+	}
+	stop()
+	os.Exit(status)
+}
+
+// lexArgs prints the items of the files denoted by the command line
+// arguments and returns the exit status of the command.
+func lexArgs(args []string) int {
+	names, walked, err := expandArgs(args, *recursive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	stream := slices.Contains(names, "-") && isPipe(os.Stdin)
+	p, err := newOutput(out, len(names) > 1, stream)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	status := 0
+	var (
+		nerrs  int // number of lexical errors.
+		failed int // number of files with lexical errors.
+	)
+	for _, name := range names {
+		ds, err := lexFile(p, name)
+		if dp, ok := p.(diagPrinter); ok {
+			dp.diagnostics(ds)
+		}
+		// Keep the errors after the output that precedes them.
+		out.Flush()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			status = 1
+		}
+		if len(ds) > 0 {
+			failed++
+			status = 1
+		}
+		for _, d := range ds {
+			fmt.Fprintf(os.Stderr, "%s:%v\n", displayName(name), d)
+		}
+		nerrs += len(ds)
+	}
+	if err := p.close(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		status = 1
+	}
+	if walked {
+		fmt.Fprintf(os.Stderr, "%d errors in %d of %d files\n", nerrs, failed, len(names))
+	}
+	return status
+}
+
+// newOutput returns the printer selected by the command line flags,
+// which writes to w. multi reports whether there are several inputs
+// and stream whether the output must be written as soon as possible.
+func newOutput(w io.Writer, multi, stream bool) (printer, error) {
+	var (
+		p   printer
+		err error
+	)
+	switch {
+	case *quiet:
+		return nopPrinter{}, nil
+	case *stats:
+		p = newStatsPrinter(w)
+	case *histogram:
+		p = newHistogramPrinter(w)
+	default:
+		var popts printOptions
+		popts.multi = multi
+		popts.stream = stream
+		popts.positions = *positions
+		popts.hexdump = *hexdump
+		if *hexdump && *format != "table" && *format != "text" {
+			return nil, fmt.Errorf("-hexdump requires the table format")
+		}
+		popts.color, err = useColor(*color)
+		if err != nil {
+			return nil, err
+		}
+		if *tmpl != "" {
+			if *format != "table" && *format != "text" {
+				return nil, fmt.Errorf("-template cannot be used with -format")
+			}
+			p, err = newTemplatePrinter(w, *tmpl)
+		} else {
+			p, err = newPrinter(*format, w, popts)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filter(p, *only, *exclude)
+}
+
+// filter returns a printer that passes to p only the items selected
+// by the -only and -exclude flags.
+func filter(p printer, only, exclude string) (printer, error) {
+	if only == "" && exclude == "" {
+		return p, nil
+	}
+	incl, err := parseTypes(only)
+	if err != nil {
+		return nil, err
+	}
+	excl, err := parseTypes(exclude)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v", err)
-		os.Exit(1)
+		return nil, err
+	}
+	keep := func(t itemType) bool {
+		return (len(incl) == 0 || incl[t]) && !excl[t]
+	}
+	return &filterPrinter{printer: p, keep: keep}, nil
+}
+
+// useColor reports whether the output must be colorized according to
+// the value of the -color flag.
+func useColor(when string) (bool, error) {
+	switch when {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		fi, err := os.Stdout.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("invalid -color value %q", when)
+}
+
+// isPipe reports whether f is a pipe.
+func isPipe(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeNamedPipe != 0
+}
+
+// expandArgs returns the names of the files denoted by the command
+// line arguments, expanding dir/... arguments and, if recursive is
+// true, directories to the .lox files they contain. walked reports
+// whether any directory was expanded.
+func expandArgs(args []string, recursive bool) (names []string, walked bool, err error) {
+	for _, arg := range args {
+		dir, ok := strings.CutSuffix(arg, "/...")
+		if !ok && recursive && arg != "-" {
+			if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+				dir, ok = arg, true
+			}
+		}
+		if !ok {
+			names = append(names, arg)
+			continue
+		}
+		walked = true
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(path) == ".lox" {
+				names = append(names, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	return names, walked, nil
+}
+
+// lexerOptions returns the options of the lexer selected by the
+// command line flags.
+func lexerOptions() []option {
+	var opts []option
+	// By default, stop at the first error.
+	if *maxErrors != 1 {
+		opts = append(opts, withRecovery(), withMaxErrors(*maxErrors))
+	}
+	if messages != nil {
+		opts = append(opts, withMessages(messages))
+	}
+	return opts
+}
+
+// wantsSource reports whether p needs the source text of the inputs.
+func wantsSource(p printer) bool {
+	if fp, ok := p.(*filterPrinter); ok {
+		p = fp.printer
+	}
+	_, ok := p.(sourcePrinter)
+	return ok
+}
+
+// printsDiagnostics reports whether p prints the diagnostics of the
+// inputs.
+func printsDiagnostics(p printer) bool {
+	if fp, ok := p.(*filterPrinter); ok {
+		p = fp.printer
+	}
+	_, ok := p.(diagPrinter)
+	return ok
+}
+
+// displayName returns the name of the named file in messages.
+func displayName(name string) string {
+	if name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+// lexFile prints the items of the named file, or of the standard
+// input if name is "-", or of the file fetched from name if it is a
+// URL. It returns the diagnostics of the scan.
+func lexFile(p printer, name string) ([]Diagnostic, error) {
+	var r io.Reader = os.Stdin
+	switch {
+	case isURL(name):
+		body, err := fetch(name)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		r = body
+	case name != "-":
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if fi, err := f.Stat(); err == nil && fi.IsDir() {
+			return nil, fmt.Errorf("%s is a directory; use -r or %s/... to lex its files", name, name)
+		}
+		r = f
+	}
+
+	if err := p.start(displayName(name)); err != nil {
+		return nil, err
+	}
+	opts := append([]option{withChanSize(ChanSizeAuto)}, lexerOptions()...)
+	if wantsSource(p) {
+		text, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		p.(sourcePrinter).source(string(text))
+		r = bytes.NewReader(text)
+		// Keep scanning after errors and highlight comments, so
+		// all the text is shown. The limit set by -max-errors, if
+		// any, still applies. Printers of diagnostics only need the
+		// text to compute columns, and report the errors selected
+		// by the flags.
+		if !printsDiagnostics(p) {
+			opts = append(opts, withRecovery(), withComments())
+		}
 	}
-	for it := range lex(string(input)) {
-		fmt.Printf("%-10s %s\n", it.typ, it.val)
+	cl := StartReaderLexer(r, opts...)
+	defer cl.Stop()
+	for it := range cl.Items() {
+		if err := p.print(it); err != nil {
+			return nil, err
+		}
 	}
+	return cl.Diagnostics(), nil
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Catalog is a message catalog. It maps the English format strings of
+// the diagnostic messages of the lexer to their translations, which
+// must take the same arguments in the same order.
+type Catalog map[string]string
+
+// translate returns the translation of msg, or msg itself if there is
+// none.
+func (c Catalog) translate(msg string) string {
+	if t, ok := c[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// catalogs are the message catalogs of the supported languages other
+// than English, by ISO 639-1 code.
+var catalogs = map[string]Catalog{
+	"es": catalogES,
+}
+
+// catalogES is the Spanish message catalog.
+var catalogES = Catalog{
+	"input exceeds %d bytes":                         "la entrada supera los %d bytes",
+	"token exceeds %d bytes":                         "el token supera los %d bytes",
+	"invalid UTF-8 encoding: %#x":                    "codificación UTF-8 no válida: %#x",
+	"non-ASCII character: %q":                        "carácter no ASCII: %q",
+	"read error: %w":                                 "error de lectura: %w",
+	"unclosed string interpolation":                  "interpolación de cadena sin cerrar",
+	"unexpected character: %c":                       "carácter inesperado: %c",
+	"unclosed comment":                               "comentario sin cerrar",
+	"unknown escape sequence: \\%c":                  "secuencia de escape desconocida: \\%c",
+	"newline in string %s":                           "salto de línea en la cadena %s",
+	"unclosed string %s":                             "cadena sin cerrar %s",
+	"exponent has no digits: %s":                     "el exponente no tiene dígitos: %s",
+	"malformed %s literal: %s":                       "literal %s mal formado: %s",
+	"invalid digit %q in %s literal":                 "dígito %q no válido en literal %s",
+	"misplaced digit separator: %s":                  "separador de dígitos mal colocado: %s",
+	"hexadecimal":                                    "hexadecimal",
+	"binary":                                         "binario",
+	"bidirectional control character %U":             "carácter de control bidireccional %U",
+	"identifier %q contains %U, which looks like %q": "el identificador %q contiene %U, que se parece a %q",
+	"invisible character %U":                         "carácter invisible %U",
+
+	"invalid escape sequence: missing '{' after \\u":                  "secuencia de escape no válida: falta '{' después de \\u",
+	"invalid escape sequence: invalid character %q in Unicode escape": "secuencia de escape no válida: carácter %q no válido en escape Unicode",
+	"invalid escape sequence: empty Unicode escape":                   "secuencia de escape no válida: escape Unicode vacío",
+	"invalid escape sequence: Unicode escape \\u{%s} out of range":    "secuencia de escape no válida: escape Unicode \\u{%s} fuera de rango",
+	"invalid escape sequence: Unicode escape \\u{%s} is a surrogate":  "secuencia de escape no válida: el escape Unicode \\u{%s} es un sustituto",
+}
+
+// messageCatalog returns the message catalog of the given language,
+// such as "es" or "es_ES.UTF-8". If lang is empty, the language is
+// taken from the LC_ALL, LC_MESSAGES and LANG environment variables,
+// and unsupported languages are ignored. It returns nil for English.
+func messageCatalog(lang string) (Catalog, error) {
+	explicit := lang != ""
+	if !explicit {
+		for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if lang = os.Getenv(env); lang != "" {
+				break
+			}
+		}
+	}
+	// Strip the territory, codeset and modifier of locale names.
+	code, _, _ := strings.Cut(lang, "_")
+	code, _, _ = strings.Cut(code, ".")
+	code, _, _ = strings.Cut(code, "@")
+	code = strings.ToLower(code)
+	switch code {
+	case "", "c", "posix", "en":
+		return nil, nil
+	}
+	c, ok := catalogs[code]
+	if !ok && explicit {
+		return nil, fmt.Errorf("unsupported language %q", lang)
+	}
+	return c, nil
+}
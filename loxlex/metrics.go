@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Metrics collects statistics about scans. The zero value is ready to
+// use. A Metrics can be shared by several scans, which then add to
+// the same counters, but not concurrently. When the lexer runs in its
+// own goroutine, it must not be read until the scan has finished.
+type Metrics struct {
+	// Items is the number of items emitted, by type.
+	Items map[itemType]int
+
+	// Bytes is the number of bytes of input scanned.
+	Bytes int
+
+	// States holds the executions of state functions, by name, such
+	// as "lexCode".
+	States map[string]*StateMetrics
+
+	names map[uintptr]string // names of the state functions seen so far.
+}
+
+// StateMetrics holds the executions of a state function.
+type StateMetrics struct {
+	// Calls is the number of executions.
+	Calls int
+
+	// Time is the total execution time. It includes the time spent
+	// waiting for the client to receive the items emitted by the
+	// state.
+	Time time.Duration
+}
+
+// addItem counts an emitted item of type t.
+func (m *Metrics) addItem(t itemType) {
+	if m.Items == nil {
+		m.Items = make(map[itemType]int)
+	}
+	m.Items[t]++
+}
+
+// addState records an execution of state that took d and scanned n
+// bytes.
+func (m *Metrics) addState(state stateFn, d time.Duration, n int) {
+	if m.States == nil {
+		m.States = make(map[string]*StateMetrics)
+		m.names = make(map[uintptr]string)
+	}
+	pc := reflect.ValueOf(state).Pointer()
+	name, ok := m.names[pc]
+	if !ok {
+		name = stateName(pc)
+		m.names[pc] = name
+	}
+	sm := m.States[name]
+	if sm == nil {
+		sm = &StateMetrics{}
+		m.States[name] = sm
+	}
+	sm.Calls++
+	sm.Time += d
+	m.Bytes += n
+}
+
+// stateName returns the name of the state function at pc, without
+// the package path.
+func stateName(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "unknown"
+	}
+	name := f.Name()
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
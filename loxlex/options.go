@@ -0,0 +1,307 @@
+package main
+
+import "maps"
+
+// LexerOptions configures the behavior of the lexer. The zero value
+// selects the default behavior.
+type LexerOptions struct {
+	// Keywords associates reserved words with the corresponding
+	// item types. If nil, the Lox keywords are used.
+	Keywords map[string]itemType
+
+	// FoldKeywords matches keywords case-insensitively, so "IF",
+	// "If" and "if" are all itemIf. Only ASCII letters are folded.
+	// The values of the items keep their original case.
+	FoldKeywords bool
+
+	// ASCIIIdents restricts identifiers to ASCII letters, digits
+	// and underscores.
+	ASCIIIdents bool
+
+	// StrictASCII rejects non-ASCII characters outside of string
+	// literals and comments, as the reference implementation of Lox
+	// does.
+	StrictASCII bool
+
+	// NormalizeNewlines replaces "\r\n" and lone "\r" line
+	// terminators with "\n" in the literal value of strings.
+	NormalizeNewlines bool
+
+	// SingleLineStrings rejects line terminators in strings other
+	// than raw strings.
+	SingleLineStrings bool
+
+	// Interpolation enables embedded expressions in strings, such
+	// as "hello ${name}". See lexString.
+	Interpolation bool
+
+	// TabWidth is the distance between tab stops used to compute
+	// the visual columns of diagnostics. Zero means 8.
+	TabWidth int
+
+	// Comments makes the lexer emit comments as itemComment or
+	// itemDocComment instead of discarding them.
+	Comments bool
+
+	// DocComments makes the lexer emit doc comments as
+	// itemDocComment even if other comments are discarded.
+	DocComments bool
+
+	// Trivia makes the lexer attach whitespace and comments to the
+	// adjacent items. See withTrivia.
+	Trivia bool
+
+	// SecurityWarnings makes the lexer report warnings about
+	// bidirectional control and invisible characters in strings and
+	// comments, and about identifiers with letters easily mistaken
+	// for Latin letters, which can make the code look different from
+	// what it does.
+	SecurityWarnings bool
+
+	// Messages, if not nil, translates the diagnostic messages.
+	Messages Catalog
+
+	// Recover makes the lexer resume scanning after an error
+	// instead of terminating the scan.
+	Recover bool
+
+	// MaxErrors is the number of errors after which the scan is
+	// terminated, even in recovery mode. Zero means no limit.
+	MaxErrors int
+
+	// MaxInput is the maximum size of the input in bytes. The scan
+	// is terminated with an error when it is exceeded. Zero means no
+	// limit.
+	MaxInput int
+
+	// MaxTokenLen is the maximum length in bytes of identifiers,
+	// strings and numbers. Comments and whitespace are not limited.
+	// The scan is terminated with an error when it is exceeded. Zero
+	// means no limit.
+	MaxTokenLen int
+
+	// Source, if not nil, records the offsets of the lines of the
+	// input as it is scanned. When the lexer runs in its own
+	// goroutine, it must not be used until the scan has finished.
+	Source *Source
+
+	// Intern, if not nil, interns the values of identifiers and
+	// keywords, so repeated names share one string.
+	Intern *Interner
+
+	// Metrics, if not nil, collects statistics about the scan.
+	Metrics *Metrics
+
+	// ChanSize is the capacity of the items channel of the lexers
+	// that run in their own goroutine. ChanSizeAuto chooses it
+	// depending on the size of the input. Zero means unbuffered.
+	ChanSize int
+}
+
+// ChanSizeAuto makes the lexer choose the capacity of the items
+// channel.
+const ChanSizeAuto = -1
+
+// option configures the behavior of the lexer.
+type option func(*LexerOptions)
+
+// withOptions replaces the configuration of the lexer with opts.
+func withOptions(opts LexerOptions) option {
+	return func(o *LexerOptions) {
+		*o = opts
+	}
+}
+
+// withKeywordTable replaces the table of reserved words, which
+// associates keywords with the corresponding item types.
+func withKeywordTable(table map[string]itemType) option {
+	return func(o *LexerOptions) {
+		o.Keywords = table
+	}
+}
+
+// withKeywords reserves additional words. They are emitted as
+// itemKeyword, with the word as value.
+func withKeywords(words ...string) option {
+	return func(o *LexerOptions) {
+		o.Keywords = keywords(o)
+		for _, w := range words {
+			o.Keywords[w] = itemKeyword
+		}
+	}
+}
+
+// withoutKeywords removes words from the table of reserved words, so
+// they are emitted as identifiers.
+func withoutKeywords(words ...string) option {
+	return func(o *LexerOptions) {
+		o.Keywords = keywords(o)
+		for _, w := range words {
+			delete(o.Keywords, w)
+		}
+	}
+}
+
+// keywords returns a copy of the table of reserved words of o.
+func keywords(o *LexerOptions) map[string]itemType {
+	if o.Keywords == nil {
+		return maps.Clone(key)
+	}
+	return maps.Clone(o.Keywords)
+}
+
+// withFoldKeywords matches keywords case-insensitively.
+func withFoldKeywords() option {
+	return func(o *LexerOptions) {
+		o.FoldKeywords = true
+	}
+}
+
+// withASCIIIdents restricts identifiers to ASCII characters.
+func withASCIIIdents() option {
+	return func(o *LexerOptions) {
+		o.ASCIIIdents = true
+	}
+}
+
+// withStrictASCII rejects non-ASCII characters outside of string
+// literals and comments.
+func withStrictASCII() option {
+	return func(o *LexerOptions) {
+		o.StrictASCII = true
+	}
+}
+
+// withNormalizeNewlines normalizes line terminators to "\n" in the
+// literal value of strings.
+func withNormalizeNewlines() option {
+	return func(o *LexerOptions) {
+		o.NormalizeNewlines = true
+	}
+}
+
+// withSingleLineStrings rejects strings that span several lines.
+func withSingleLineStrings() option {
+	return func(o *LexerOptions) {
+		o.SingleLineStrings = true
+	}
+}
+
+// withInterpolation enables embedded expressions in strings.
+func withInterpolation() option {
+	return func(o *LexerOptions) {
+		o.Interpolation = true
+	}
+}
+
+// withTabWidth sets the distance between tab stops used to compute
+// visual columns.
+func withTabWidth(n int) option {
+	return func(o *LexerOptions) {
+		o.TabWidth = n
+	}
+}
+
+// withTrivia makes the lexer attach whitespace and comments to the
+// adjacent items instead of discarding them, so the input can be
+// reconstructed from the items. Trivia following an item up to the
+// end of its line, newline included, is attached to that item as
+// trailing trivia. The rest is attached to the next item as leading
+// trivia.
+func withTrivia() option {
+	return func(o *LexerOptions) {
+		o.Trivia = true
+	}
+}
+
+// withComments makes the lexer emit comments as itemComment or
+// itemDocComment instead of discarding them.
+func withComments() option {
+	return func(o *LexerOptions) {
+		o.Comments = true
+	}
+}
+
+// withDocComments makes the lexer emit doc comments as
+// itemDocComment.
+func withDocComments() option {
+	return func(o *LexerOptions) {
+		o.DocComments = true
+	}
+}
+
+// withSecurityWarnings reports warnings about characters that can
+// make the code look different from what it does.
+func withSecurityWarnings() option {
+	return func(o *LexerOptions) {
+		o.SecurityWarnings = true
+	}
+}
+
+// withMessages translates the diagnostic messages with the given
+// message catalog.
+func withMessages(c Catalog) option {
+	return func(o *LexerOptions) {
+		o.Messages = c
+	}
+}
+
+// withRecovery makes the lexer resume scanning after an error instead
+// of terminating the scan. The offending input is skipped.
+func withRecovery() option {
+	return func(o *LexerOptions) {
+		o.Recover = true
+	}
+}
+
+// withMaxErrors terminates the scan after n errors.
+func withMaxErrors(n int) option {
+	return func(o *LexerOptions) {
+		o.MaxErrors = n
+	}
+}
+
+// withMaxInput limits the size of the input to n bytes.
+func withMaxInput(n int) option {
+	return func(o *LexerOptions) {
+		o.MaxInput = n
+	}
+}
+
+// withMaxTokenLen limits the length of identifiers, strings and
+// numbers to n bytes.
+func withMaxTokenLen(n int) option {
+	return func(o *LexerOptions) {
+		o.MaxTokenLen = n
+	}
+}
+
+// withInterning interns the values of identifiers and keywords in
+// table.
+func withInterning(table *Interner) option {
+	return func(o *LexerOptions) {
+		o.Intern = table
+	}
+}
+
+// withMetrics collects statistics about the scan in m.
+func withMetrics(m *Metrics) option {
+	return func(o *LexerOptions) {
+		o.Metrics = m
+	}
+}
+
+// withSource records the offsets of the lines of the input in src.
+func withSource(src *Source) option {
+	return func(o *LexerOptions) {
+		o.Source = src
+	}
+}
+
+// withChanSize sets the capacity of the items channel. n can be
+// ChanSizeAuto.
+func withChanSize(n int) option {
+	return func(o *LexerOptions) {
+		o.ChanSize = n
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printer prints the items of the inputs of loxlex in some format.
+type printer interface {
+	// start is called before the items of each input.
+	start(name string) error
+
+	// print prints an item of the current input.
+	print(it item) error
+
+	// close is called after the last input.
+	close() error
+}
+
+// printOptions configures printers.
+type printOptions struct {
+	multi     bool // There are several inputs.
+	color     bool // Colorize the output, if supported by the format.
+	positions bool // Prefix the rows with file:line:col, if supported by the format.
+	stream    bool // Write every item as soon as it is printed, if supported by the format.
+	hexdump   bool // Dump the bytes of every item; only supported by the table format.
+}
+
+// formats are the output formats supported by newPrinter.
+var formats = []string{"table", "text", "json", "ndjson", "csv", "html", "sarif", "binary", "proto"}
+
+// newPrinter returns a printer that writes to w in the given format.
+func newPrinter(format string, w io.Writer, opts printOptions) (printer, error) {
+	switch format {
+	case "table", "text":
+		p := &tablePrinter{
+			w:         bufio.NewWriter(w),
+			headers:   opts.multi && !opts.positions,
+			color:     opts.color,
+			positions: opts.positions,
+		}
+		if opts.hexdump {
+			return &hexdumpPrinter{tablePrinter: p}, nil
+		}
+		return p, nil
+	case "json", "ndjson":
+		jw := newJSONWriter(w, format == "ndjson")
+		jw.stream = opts.stream && jw.ndjson
+		return &jsonPrinter{jw: jw, names: opts.multi}, nil
+	case "csv":
+		return newCSVPrinter(w, opts.multi), nil
+	case "html":
+		return newHTMLPrinter(w), nil
+	case "sarif":
+		return newSARIFPrinter(w), nil
+	}
+	if opts.multi {
+		return nil, fmt.Errorf("format %s supports a single input", format)
+	}
+	switch format {
+	case "binary":
+		return &binaryPrinter{e: newBinaryEncoder(w)}, nil
+	case "proto":
+		return &protoPrinter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// sourcePrinter is implemented by printers that print the source text
+// of the inputs and not only their items.
+type sourcePrinter interface {
+	printer
+
+	// source is called after start with the text of the input.
+	source(text string)
+}
+
+// filterPrinter passes to another printer only the items for which
+// keep returns true.
+type filterPrinter struct {
+	printer
+	keep func(itemType) bool
+}
+
+func (p *filterPrinter) source(text string) {
+	if sp, ok := p.printer.(sourcePrinter); ok {
+		sp.source(text)
+	}
+}
+
+func (p *filterPrinter) diagnostics(ds []Diagnostic) {
+	if dp, ok := p.printer.(diagPrinter); ok {
+		dp.diagnostics(ds)
+	}
+}
+
+func (p *filterPrinter) print(it item) error {
+	if !p.keep(it.typ) {
+		return nil
+	}
+	return p.printer.print(it)
+}
+
+// parseTypes parses a comma-separated list of item type names, such
+// as "Identifier,String". Names are case-insensitive.
+func parseTypes(list string) (map[itemType]bool, error) {
+	types := make(map[itemType]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for t := itemError; t <= itemEOF; t++ {
+			if strings.EqualFold(t.String(), name) {
+				types[t], found = true, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown token type %q", name)
+		}
+	}
+	return types, nil
+}
+
+// tablePrinter prints one item per line, in aligned columns.
+type tablePrinter struct {
+	w         *bufio.Writer
+	headers   bool   // whether to print the name of every input.
+	color     bool   // whether to colorize the items by category.
+	positions bool   // whether to prefix the rows with file:line:col.
+	n         int    // number of inputs so far.
+	name      string // name of the current input.
+}
+
+func (p *tablePrinter) start(name string) error {
+	p.name = name
+	if p.headers {
+		if p.n > 0 {
+			p.w.WriteString("\n")
+		}
+		fmt.Fprintf(p.w, "==> %s <==\n", name)
+	}
+	p.n++
+	return nil
+}
+
+func (p *tablePrinter) print(it item) error {
+	pos := fmt.Sprintf("%d:%d", it.line, it.col)
+	if p.positions {
+		pos = fmt.Sprintf("%s:%s:", p.name, pos)
+	}
+	c := ""
+	if p.color {
+		c = itemColor(it.typ)
+	}
+	if c == "" {
+		_, err := fmt.Fprintf(p.w, "%-8s %-10s %s\n", pos, it.typ, it.Val())
+		return err
+	}
+	_, err := fmt.Fprintf(p.w, "%-8s %s%-10s %s%s\n", pos, c, it.typ, it.Val(), colorReset)
+	return err
+}
+
+// ANSI escape sequences used to colorize the output.
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorGray    = "\x1b[90m"
+)
+
+// itemColor returns the escape sequence that sets the color of the
+// items of type t, or the empty string if they are not colorized.
+func itemColor(t itemType) string {
+	if t == itemError {
+		return colorRed
+	}
+	switch t.Category() {
+	case CategoryKeyword:
+		return colorMagenta
+	case CategoryLiteral:
+		return colorGreen
+	case CategoryOperator:
+		return colorYellow
+	case CategoryPunctuation:
+		return colorBlue
+	case CategoryTrivia:
+		return colorGray
+	}
+	return ""
+}
+
+func (p *tablePrinter) close() error {
+	return p.w.Flush()
+}
+
+// nopPrinter discards the items of all the inputs.
+type nopPrinter struct{}
+
+func (nopPrinter) start(name string) error { return nil }
+func (nopPrinter) print(it item) error     { return nil }
+func (nopPrinter) close() error            { return nil }
+
+// jsonPrinter prints the items of all the inputs as a JSON array or
+// as NDJSON.
+type jsonPrinter struct {
+	jw    *jsonWriter
+	names bool   // whether to include the name of the input.
+	name  string // name of the current input.
+}
+
+func (p *jsonPrinter) start(name string) error {
+	if p.names {
+		p.name = name
+	}
+	return nil
+}
+
+func (p *jsonPrinter) print(it item) error {
+	return p.jw.write(newJSONItem(p.name, it))
+}
+
+func (p *jsonPrinter) close() error {
+	return p.jw.close()
+}
+
+// csvPrinter prints the items as CSV records, preceded by a header.
+type csvPrinter struct {
+	w     *csv.Writer
+	names bool   // whether to include the name of the input.
+	name  string // name of the current input.
+}
+
+// csvHeader holds the names of the fields of the CSV records.
+var csvHeader = []string{"type", "value", "literal", "line", "col", "start", "end"}
+
+func newCSVPrinter(w io.Writer, names bool) *csvPrinter {
+	p := &csvPrinter{w: csv.NewWriter(w), names: names}
+	header := csvHeader
+	if names {
+		header = append([]string{"file"}, header...)
+	}
+	p.w.Write(header)
+	return p
+}
+
+func (p *csvPrinter) start(name string) error {
+	p.name = name
+	return nil
+}
+
+func (p *csvPrinter) print(it item) error {
+	rec := []string{
+		it.typ.String(),
+		it.Val(),
+		it.lit,
+		strconv.Itoa(it.line),
+		strconv.Itoa(it.col),
+		strconv.Itoa(it.start),
+		strconv.Itoa(it.end),
+	}
+	if p.names {
+		rec = append([]string{p.name}, rec...)
+	}
+	return p.w.Write(rec)
+}
+
+func (p *csvPrinter) close() error {
+	p.w.Flush()
+	return p.w.Error()
+}
+
+// binaryPrinter prints the items as a binary token stream.
+type binaryPrinter struct {
+	e *binaryEncoder
+}
+
+func (p *binaryPrinter) start(name string) error {
+	return nil
+}
+
+func (p *binaryPrinter) print(it item) error {
+	return p.e.Encode(it)
+}
+
+func (p *binaryPrinter) close() error {
+	return p.e.Flush()
+}
+
+// protoPrinter prints the items as a TokenStream protocol buffer.
+type protoPrinter struct {
+	w     *bufio.Writer
+	items []item
+}
+
+func (p *protoPrinter) start(name string) error {
+	return nil
+}
+
+func (p *protoPrinter) print(it item) error {
+	p.items = append(p.items, it)
+	return nil
+}
+
+func (p *protoPrinter) close() error {
+	p.w.Write(marshalTokens(p.items))
+	return p.w.Flush()
+}
+
+// statsPrinter prints statistics about the items of all the inputs
+// instead of the items themselves.
+type statsPrinter struct {
+	w      io.Writer
+	t0     time.Time
+	counts map[itemType]int
+	files  int
+	halted int // number of inputs whose scan stopped at an error.
+	bytes  int
+	lines  int
+	last   item // last item of the current input.
+}
+
+func newStatsPrinter(w io.Writer) *statsPrinter {
+	return &statsPrinter{w: w, t0: time.Now(), counts: make(map[itemType]int)}
+}
+
+func (p *statsPrinter) start(name string) error {
+	p.endInput()
+	p.files++
+	return nil
+}
+
+func (p *statsPrinter) print(it item) error {
+	if it.typ != itemEOF {
+		p.counts[it.typ]++
+	}
+	p.last = it
+	return nil
+}
+
+// endInput adds the size of the current input, if any, to the totals.
+// If the scan stopped at an error, only the input up to the error is
+// counted, as the rest has not been scanned.
+func (p *statsPrinter) endInput() {
+	if p.files == 0 {
+		return
+	}
+	if p.last.typ == itemError {
+		p.halted++
+	}
+	p.bytes += p.last.end
+	if p.last.end > 0 {
+		p.lines += p.last.line
+		if p.last.typ == itemEOF && p.last.col == 1 {
+			// The input ends with a line terminator.
+			p.lines--
+		}
+	}
+	p.last = item{}
+}
+
+func (p *statsPrinter) close() error {
+	p.endInput()
+	elapsed := time.Since(p.t0)
+
+	total := 0
+	types := make([]itemType, 0, len(p.counts))
+	for t, n := range p.counts {
+		types = append(types, t)
+		total += n
+	}
+	slices.SortFunc(types, func(a, b itemType) int {
+		return cmp.Or(cmp.Compare(p.counts[b], p.counts[a]), cmp.Compare(a, b))
+	})
+
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Files\t%d\n", p.files)
+	if p.halted > 0 {
+		fmt.Fprintf(tw, "Halted\t%d, counted up to the error\n", p.halted)
+	}
+	fmt.Fprintf(tw, "Tokens\t%d\n", total)
+	fmt.Fprintf(tw, "Bytes\t%d\n", p.bytes)
+	fmt.Fprintf(tw, "Lines\t%d\n", p.lines)
+	fmt.Fprintf(tw, "Time\t%v\n", elapsed.Round(time.Microsecond))
+	if secs := elapsed.Seconds(); secs > 0 {
+		fmt.Fprintf(tw, "Throughput\t%.2f MB/s, %.0f tokens/s\n", float64(p.bytes)/secs/1e6, float64(total)/secs)
+	}
+	fmt.Fprintf(tw, "\nType\tCount\n")
+	for _, t := range types {
+		fmt.Fprintf(tw, "%v\t%d\n", t, p.counts[t])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if bw, ok := p.w.(*bufio.Writer); ok {
+		return bw.Flush()
+	}
+	return nil
+}
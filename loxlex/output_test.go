@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStatsPrinter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []option
+		want  []string // lines expected in the output.
+	}{
+		{"Complete", "a b\nc d\n", nil, []string{"Files       1", "Tokens      4", "Bytes       8", "Lines       2"}},
+		{"NoFinalNewline", "a b\nc", nil, []string{"Tokens      3", "Bytes       5", "Lines       2"}},
+		// The scan stops at the error, so the rest of the input
+		// is not counted.
+		{"Halted", "a b @ c d\ne f\n", nil, []string{"Halted      1, counted up to the error", "Tokens      3", "Bytes       5", "Lines       1"}},
+		{"Recovered", "a b @ c d\ne f\n", []option{withRecovery()}, []string{"Tokens      7", "Bytes       14", "Lines       2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			p := newStatsPrinter(&sb)
+			if err := p.start("test.lox"); err != nil {
+				t.Fatal(err)
+			}
+			for it := range Lex(tt.input, tt.opts...) {
+				if err := p.print(it); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := p.close(); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(sb.String(), "\n")
+			for _, want := range tt.want {
+				if !slices.Contains(lines, want) {
+					t.Errorf("no line %q in output:\n%s", want, sb.String())
+				}
+			}
+			if halted := strings.Contains(sb.String(), "Halted"); halted != (tt.name == "Halted") {
+				t.Errorf("got Halted line %v in output:\n%s", halted, sb.String())
+			}
+		})
+	}
+}
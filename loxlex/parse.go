@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// parseMain implements the parse subcommand, which parses files and
+// prints their syntax trees.
+func parseMain(args []string) int {
+	fs := flag.NewFlagSet("loxlex parse", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: loxlex parse [flags] [file ...]\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "tree", "output `format`: tree, sexpr, json or dot")
+	keepFor := fs.Bool("keepfor", false, "keep for loops instead of desugaring them into while loops")
+	foldConst := fs.Bool("fold", false, "fold constant expressions")
+	resolveVars := fs.Bool("resolve", false, "resolve the local variables and report the errors and warnings of the static analyses")
+	fs.Parse(args)
+
+	// Formats print either every statement or whole files.
+	var (
+		printStmt func(io.Writer, ast.Stmt)
+		printFile func(w io.Writer, name string, stmts []ast.Stmt) error
+	)
+	switch *format {
+	case "tree":
+		printStmt = func(w io.Writer, s ast.Stmt) { printTree(w, s, 0) }
+	case "sexpr":
+		printStmt = func(w io.Writer, s ast.Stmt) { fmt.Fprintln(w, sexpr(s)) }
+	case "json":
+		printFile = writeASTJSON
+	case "dot":
+		printFile = writeDOT
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown format %q\n", *format)
+		return 2
+	}
+
+	po := ParserOptions{KeepFor: *keepFor}
+
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	status := 0
+	for _, name := range names {
+		text, err := readFile(name)
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			status = 1
+			continue
+		}
+		stmts, diags := po.parse(text, withMessages(messages))
+		// As in jlox, the program is only resolved if it is
+		// syntactically correct.
+		if *resolveVars && len(diags) == 0 {
+			diags = analyze(stmts)
+		}
+		if *foldConst {
+			stmts = fold(stmts)
+		}
+		if printFile != nil {
+			if err := printFile(out, displayName(name), stmts); err != nil {
+				out.Flush()
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+		} else {
+			if len(names) > 1 {
+				fmt.Fprintf(out, "==> %s <==\n", displayName(name))
+			}
+			for _, s := range stmts {
+				printStmt(out, s)
+			}
+		}
+		// Keep the errors after the output that precedes them.
+		out.Flush()
+		for _, d := range diags {
+			writeSnippet(os.Stderr, displayName(name), text, d)
+			if d.Severity == SeverityError {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// printTree prints n and its descendants to w, one per line, indented
+// by depth.
+func printTree(w io.Writer, n ast.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	line := func(format string, args ...any) {
+		fmt.Fprintf(w, "%s%v: ", indent, n.Pos())
+		fmt.Fprintf(w, format+"\n", args...)
+	}
+	children := func(label string, nodes ...ast.Node) {
+		fmt.Fprintf(w, "%s  %s:\n", indent, label)
+		for _, c := range nodes {
+			printTree(w, c, depth+2)
+		}
+	}
+
+	switch n := n.(type) {
+	case *ast.Assign:
+		line("Assign %s%s", n.Name.Name, depthString(n.Depth))
+		printTree(w, n.Value, depth+1)
+	case *ast.Binary:
+		line("Binary %s", n.Op)
+		printTree(w, n.X, depth+1)
+		printTree(w, n.Y, depth+1)
+	case *ast.Call:
+		line("Call")
+		printTree(w, n.Callee, depth+1)
+		if len(n.Args) > 0 {
+			children("args", exprNodes(n.Args)...)
+		}
+	case *ast.Conditional:
+		line("Conditional")
+		children("cond", n.Cond)
+		children("then", n.Then)
+		children("else", n.Else)
+	case *ast.Get:
+		line("Get %s", n.Name.Name)
+		printTree(w, n.X, depth+1)
+	case *ast.Grouping:
+		line("Grouping")
+		printTree(w, n.X, depth+1)
+	case *ast.Literal:
+		line("Literal %s", literalString(n.Value))
+	case *ast.Logical:
+		line("Logical %s", n.Op)
+		printTree(w, n.X, depth+1)
+		printTree(w, n.Y, depth+1)
+	case *ast.Set:
+		line("Set %s", n.Name.Name)
+		printTree(w, n.X, depth+1)
+		printTree(w, n.Value, depth+1)
+	case *ast.Super:
+		line("Super %s%s", n.Method.Name, depthString(n.Depth))
+	case *ast.This:
+		line("This%s", depthString(n.Depth))
+	case *ast.Unary:
+		line("Unary %s", n.Op)
+		printTree(w, n.X, depth+1)
+	case *ast.Variable:
+		line("Variable %s%s", n.Name.Name, depthString(n.Depth))
+
+	case *ast.Block:
+		line("Block")
+		for _, s := range n.Stmts {
+			printTree(w, s, depth+1)
+		}
+	case *ast.Class:
+		if n.Superclass != nil {
+			line("Class %s < %s", n.Name.Name, n.Superclass.Name.Name)
+		} else {
+			line("Class %s", n.Name.Name)
+		}
+		for _, m := range n.Methods {
+			printTree(w, m, depth+1)
+		}
+	case *ast.Expression:
+		line("Expression")
+		printTree(w, n.X, depth+1)
+	case *ast.For:
+		line("For")
+		if n.Init != nil {
+			children("init", n.Init)
+		}
+		if n.Cond != nil {
+			children("cond", n.Cond)
+		}
+		if n.Incr != nil {
+			children("incr", n.Incr)
+		}
+		children("body", n.Body)
+	case *ast.Fun:
+		params := make([]string, len(n.Params))
+		for i, p := range n.Params {
+			params[i] = p.Name
+		}
+		line("Fun %s(%s)", n.Name.Name, strings.Join(params, ", "))
+		for _, s := range n.Body.Stmts {
+			printTree(w, s, depth+1)
+		}
+	case *ast.If:
+		line("If")
+		children("cond", n.Cond)
+		children("then", n.Then)
+		if n.Else != nil {
+			children("else", n.Else)
+		}
+	case *ast.Print:
+		line("Print")
+		printTree(w, n.X, depth+1)
+	case *ast.Return:
+		line("Return")
+		if n.Value != nil {
+			printTree(w, n.Value, depth+1)
+		}
+	case *ast.Var:
+		line("Var %s", n.Name.Name)
+		if n.Init != nil {
+			printTree(w, n.Init, depth+1)
+		}
+	case *ast.While:
+		line("While")
+		children("cond", n.Cond)
+		children("body", n.Body)
+	default:
+		panic(fmt.Sprintf("unexpected node %T", n))
+	}
+}
+
+// depthString returns the description of the depth of a resolved
+// local variable, or an empty string for global variables.
+func depthString(depth int) string {
+	if depth < 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (depth %d)", depth)
+}
+
+// exprNodes converts a slice of expressions to a slice of nodes.
+func exprNodes(exprs []ast.Expr) []ast.Node {
+	nodes := make([]ast.Node, len(exprs))
+	for i, e := range exprs {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+// literalString returns the representation of the value of a literal
+// expression.
+func literalString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// Diagnostic codes of the parser.
+const (
+	CodeSyntaxError = "LOX1001"
+	CodeTooManyArgs = "LOX1002"
+	CodeTooDeep     = "LOX1003"
+)
+
+// maxArgs is the maximum number of arguments of a call and of
+// parameters of a function.
+const maxArgs = 255
+
+// maxDepth is the maximum nesting depth of the statements, functions
+// and expressions, which bounds the recursion of the parser. Every
+// level of parentheses in an expression takes several levels.
+const maxDepth = 1000
+
+// parser is a recursive descent parser for Lox, following the grammar
+// of [Crafting Interpreters]. Binary expressions are parsed by
+// precedence climbing on the table infixOps.
+//
+// After a syntax error, the parser abandons the current declaration
+// and skips to the start of the next statement, so that several errors
+// can be reported per file.
+//
+// [Crafting Interpreters]: https://craftinginterpreters.com/appendix-i.html
+type parser struct {
+	input   string
+	lx      *Lexer
+	src     *Source // lines of input, recorded by the lexer.
+	keepFor bool    // whether to keep for loops instead of desugaring them.
+	tok     item    // current item.
+	prev    item    // previous item.
+	depth   int     // nesting depth of the rule being parsed.
+	diags   []Diagnostic
+}
+
+// bailout is the panic value used to abandon the current declaration
+// after an error.
+type bailout struct{}
+
+// tooDeep is the panic value used to abandon the whole program when
+// it is nested too deeply.
+type tooDeep struct{}
+
+// ParserOptions configures the behavior of the parser. The zero value
+// selects the default behavior. The lexer is configured separately,
+// with the options given to [ParserOptions.parse].
+type ParserOptions struct {
+	// KeepFor makes the parser keep for loops in the syntax tree
+	// instead of desugaring them into while loops.
+	KeepFor bool
+}
+
+// parse parses the Lox program input with the default parser options.
+// See [ParserOptions.parse].
+func parse(input string, opts ...option) ([]ast.Stmt, []Diagnostic) {
+	return ParserOptions{}.parse(input, opts...)
+}
+
+// parse parses the Lox program input, scanned by a lexer configured
+// with opts. It returns the statements of the program and the lexical
+// and syntax errors found.
+//
+// The lexer recovers from errors, and the tokens it cannot scan cause
+// the enclosing declaration to be skipped without further errors.
+//
+// The names of the syntax tree are interned, in the table given with
+// withInterning, if any, so they can be compared by identity.
+func (po ParserOptions) parse(input string, opts ...option) ([]ast.Stmt, []Diagnostic) {
+	src := NewSource("")
+	opts = append([]option{withRecovery(), withInterning(NewInterner())}, opts...)
+	opts = append(opts, withSource(src))
+	p := &parser{input: input, lx: NewLexer(input, opts...), src: src, keepFor: po.KeepFor}
+	stmts := p.parseProgram()
+	diags := append(p.lx.Diagnostics(), p.diags...)
+	slices.SortStableFunc(diags, func(a, b Diagnostic) int {
+		return cmp.Compare(a.Pos.Offset, b.Pos.Offset)
+	})
+	return stmts, diags
+}
+
+// parseProgram parses the whole input, or up to the point where it is
+// nested too deeply.
+//
+//	program → declaration* EOF
+func (p *parser) parseProgram() (stmts []ast.Stmt) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(tooDeep); !ok {
+				panic(e)
+			}
+		}
+	}()
+
+	p.next()
+	for !p.check(itemEOF) {
+		if s := p.declaration(); s != nil {
+			stmts = append(stmts, s)
+		}
+	}
+	return stmts
+}
+
+// next advances to the next item. Lexical errors are returned as
+// items of type itemError, which no rule of the grammar accepts.
+func (p *parser) next() {
+	p.prev = p.tok
+	p.tok = p.lx.Next()
+}
+
+// synchronize skips items after a syntax error until the start of the
+// next statement, which is either after a semicolon or at a keyword
+// that starts a statement.
+func (p *parser) synchronize() {
+	p.next()
+	for !p.check(itemEOF) {
+		if p.prev.typ == itemSemicolon {
+			return
+		}
+		switch p.tok.typ {
+		case itemClass, itemFun, itemVar, itemFor, itemIf, itemWhile, itemPrint, itemReturn:
+			return
+		}
+		p.next()
+	}
+}
+
+// check reports whether the current item is of type t.
+func (p *parser) check(t itemType) bool {
+	return p.tok.typ == t
+}
+
+// match advances if the current item is of any of the given types
+// and reports whether it did.
+func (p *parser) match(types ...itemType) bool {
+	if slices.Contains(types, p.tok.typ) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect advances if the current item is of type t and returns it.
+// Otherwise, it reports a syntax error with the given description of
+// the expected item.
+func (p *parser) expect(t itemType, what string) item {
+	if !p.check(t) {
+		p.errorf(p.tok, "expected %s, found %s", what, describe(p.tok))
+	}
+	p.next()
+	return p.prev
+}
+
+// expectClosing is like expect for the item that closes a construct
+// opened by the single-byte item at open, such as a parenthesis. The
+// syntax error notes where the construct was opened.
+func (p *parser) expectClosing(t itemType, what string, open ast.Pos) item {
+	if !p.check(t) {
+		d := p.report(p.tok, CodeSyntaxError, "expected %s, found %s", what, describe(p.tok))
+		if d != nil {
+			msg := fmt.Sprintf("'%s' was here", p.input[open.Offset:open.Offset+1])
+			if t == itemRightParen || t == itemRightBrace {
+				msg = "opening " + msg
+			}
+			d.Notes = append(d.Notes, Note{
+				Pos: position(open),
+				End: Position{Offset: open.Offset + 1, Line: open.Line, Col: open.Col + 1},
+				Msg: msg,
+			})
+		}
+		panic(bailout{})
+	}
+	p.next()
+	return p.prev
+}
+
+// enter increases the nesting depth when a rule that may be nested
+// starts. It must be followed by a deferred call of leave. If the
+// program is nested too deeply, the parser does not try to recover,
+// which would report an error for every enclosing construct, and
+// abandons the rest of the program.
+func (p *parser) enter() {
+	p.depth++
+	if p.depth > maxDepth {
+		p.report(p.tok, CodeTooDeep, "nesting too deep (max depth %d)", maxDepth)
+		panic(tooDeep{})
+	}
+}
+
+// leave decreases the nesting depth when a rule ends.
+func (p *parser) leave() {
+	p.depth--
+}
+
+// errorf reports a syntax error at it and abandons the current
+// declaration.
+func (p *parser) errorf(it item, format string, args ...any) {
+	p.report(it, CodeSyntaxError, format, args...)
+	panic(bailout{})
+}
+
+// report reports an error with the given code at it and returns the
+// diagnostic, which is valid until the next error. Errors at lexical
+// errors, which have already been reported by the lexer, are ignored,
+// and then it returns nil.
+func (p *parser) report(it item, code, format string, args ...any) *Diagnostic {
+	if it.typ == itemError {
+		return nil
+	}
+	return p.reportSpan(pos(it), p.end(it), code, format, args...)
+}
+
+// reportSpan reports an error with the given code that spans the
+// source code from start to end, such as a node of the syntax tree,
+// and returns the diagnostic, which is valid until the next error.
+func (p *parser) reportSpan(start, end ast.Pos, code, format string, args ...any) *Diagnostic {
+	p.diags = append(p.diags, Diagnostic{
+		Pos:      position(start),
+		End:      position(end),
+		Severity: SeverityError,
+		Code:     code,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+	return &p.diags[len(p.diags)-1]
+}
+
+// position converts p to a position of a diagnostic.
+func position(p ast.Pos) Position {
+	return Position{Offset: p.Offset, Line: p.Line, Col: p.Col}
+}
+
+// pos returns the position of the start of it.
+func pos(it item) ast.Pos {
+	return ast.Pos{Offset: it.start, Line: it.line, Col: it.col}
+}
+
+// end returns the position just past the end of it. The lines of it
+// have already been recorded in p.src, since the lexer has scanned it.
+func (p *parser) end(it item) ast.Pos {
+	line, col := p.src.Position(it.end)
+	return ast.Pos{Offset: it.end, Line: line, Col: col}
+}
+
+// ident returns the identifier it.
+func ident(it item) *ast.Ident {
+	return &ast.Ident{NamePos: pos(it), Name: it.Val()}
+}
+
+// describe returns the description of it in syntax errors.
+func describe(it item) string {
+	if it.typ == itemEOF {
+		return "end of file"
+	}
+	return strconv.Quote(preview(it.Val()))
+}
+
+// declaration parses a declaration or a statement. After a syntax
+// error, it synchronizes and returns nil.
+//
+//	declaration → classDecl | funDecl | varDecl | statement
+func (p *parser) declaration() (s ast.Stmt) {
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+			p.synchronize()
+			s = nil
+		}
+	}()
+
+	switch {
+	case p.match(itemClass):
+		return p.classDecl()
+	case p.match(itemFun):
+		keyword := p.prev
+		s := p.function("function")
+		s.Keyword = pos(keyword)
+		return s
+	case p.match(itemVar):
+		return p.varDecl()
+	}
+	return p.statement()
+}
+
+// classDecl parses a class declaration after the class keyword.
+//
+//	classDecl → "class" IDENTIFIER ( "<" IDENTIFIER )? "{" function* "}"
+func (p *parser) classDecl() ast.Stmt {
+	s := &ast.Class{Keyword: pos(p.prev)}
+	s.Name = ident(p.expect(itemIdentifier, "class name"))
+	if p.match(itemLess) {
+		s.Superclass = &ast.Variable{Name: ident(p.expect(itemIdentifier, "superclass name")), Depth: -1}
+	}
+	lbrace := p.expect(itemLeftBrace, "'{' before class body")
+	for !p.check(itemRightBrace) && !p.check(itemEOF) {
+		s.Methods = append(s.Methods, p.function("method"))
+	}
+	s.Rbrace = pos(p.expectClosing(itemRightBrace, "'}' after class body", pos(lbrace)))
+	return s
+}
+
+// function parses a function declaration after the fun keyword, or a
+// method. kind is used in error messages.
+//
+//	function   → IDENTIFIER "(" parameters? ")" block
+//	parameters → IDENTIFIER ( "," IDENTIFIER )*
+func (p *parser) function(kind string) *ast.Fun {
+	p.enter()
+	defer p.leave()
+
+	s := &ast.Fun{Name: ident(p.expect(itemIdentifier, kind+" name"))}
+	lparen := p.expect(itemLeftParen, "'(' after "+kind+" name")
+	if !p.check(itemRightParen) {
+		for {
+			if len(s.Params) == maxArgs {
+				p.report(p.tok, CodeTooManyArgs, "too many parameters (max %d)", maxArgs)
+			}
+			s.Params = append(s.Params, ident(p.expect(itemIdentifier, "parameter name")))
+			if !p.match(itemComma) {
+				break
+			}
+		}
+	}
+	p.expectClosing(itemRightParen, "')' after parameters", pos(lparen))
+	p.expect(itemLeftBrace, "'{' before "+kind+" body")
+	s.Body = p.block()
+	return s
+}
+
+// varDecl parses a variable declaration after the var keyword.
+//
+//	varDecl → "var" IDENTIFIER ( "=" expression )? ";"
+func (p *parser) varDecl() ast.Stmt {
+	s := &ast.Var{Keyword: pos(p.prev)}
+	s.Name = ident(p.expect(itemIdentifier, "variable name"))
+	if p.match(itemEqual) {
+		s.Init = p.expression()
+	}
+	s.Semicolon = pos(p.expect(itemSemicolon, "';' after variable declaration"))
+	return s
+}
+
+// statement parses a statement.
+//
+//	statement → exprStmt | forStmt | ifStmt | printStmt | returnStmt
+//	          | whileStmt | block
+func (p *parser) statement() ast.Stmt {
+	p.enter()
+	defer p.leave()
+
+	switch {
+	case p.match(itemFor):
+		return p.forStmt()
+	case p.match(itemIf):
+		return p.ifStmt()
+	case p.match(itemPrint):
+		s := &ast.Print{Keyword: pos(p.prev)}
+		s.X = p.expression()
+		s.Semicolon = pos(p.expect(itemSemicolon, "';' after value"))
+		return s
+	case p.match(itemReturn):
+		s := &ast.Return{Keyword: pos(p.prev)}
+		if !p.check(itemSemicolon) {
+			s.Value = p.expression()
+		}
+		s.Semicolon = pos(p.expect(itemSemicolon, "';' after return value"))
+		return s
+	case p.match(itemWhile):
+		s := &ast.While{Keyword: pos(p.prev)}
+		lparen := p.expect(itemLeftParen, "'(' after 'while'")
+		s.Cond = p.expression()
+		p.expectClosing(itemRightParen, "')' after condition", pos(lparen))
+		s.Body = p.statement()
+		return s
+	case p.match(itemLeftBrace):
+		return p.block()
+	}
+	s := &ast.Expression{X: p.expression()}
+	s.Semicolon = pos(p.expect(itemSemicolon, "';' after expression"))
+	return s
+}
+
+// forStmt parses a for loop after the for keyword. Unless the parser
+// keeps for loops, it returns the equivalent while loop.
+//
+//	forStmt → "for" "(" ( varDecl | exprStmt | ";" ) expression? ";"
+//	          expression? ")" statement
+func (p *parser) forStmt() ast.Stmt {
+	s := &ast.For{Keyword: pos(p.prev)}
+	lparen := p.expect(itemLeftParen, "'(' after 'for'")
+	switch {
+	case p.match(itemSemicolon):
+	case p.match(itemVar):
+		s.Init = p.varDecl()
+	default:
+		init := &ast.Expression{X: p.expression()}
+		init.Semicolon = pos(p.expect(itemSemicolon, "';' after loop initializer"))
+		s.Init = init
+	}
+	if !p.check(itemSemicolon) {
+		s.Cond = p.expression()
+	}
+	p.expect(itemSemicolon, "';' after loop condition")
+	if !p.check(itemRightParen) {
+		s.Incr = p.expression()
+	}
+	p.expectClosing(itemRightParen, "')' after for clauses", pos(lparen))
+	s.Body = p.statement()
+	if p.keepFor {
+		return s
+	}
+	return desugarFor(s)
+}
+
+// desugarFor returns the while loop equivalent to the for loop s, as
+// in Crafting Interpreters:
+//
+//	{
+//		Init;
+//		while (Cond) {
+//			Body;
+//			Incr;
+//		}
+//	}
+//
+// The blocks are omitted when there is no Init or Incr, and a missing
+// Cond is replaced by true. The while loop is positioned at the for
+// keyword.
+func desugarFor(s *ast.For) ast.Stmt {
+	body := s.Body
+	if s.Incr != nil {
+		body = &ast.Block{Stmts: []ast.Stmt{body, &ast.Expression{X: s.Incr}}}
+	}
+	cond := s.Cond
+	if cond == nil {
+		cond = &ast.Literal{Value: true, Raw: "true"}
+	}
+	var loop ast.Stmt = &ast.While{Keyword: s.Keyword, Cond: cond, Body: body}
+	if s.Init != nil {
+		loop = &ast.Block{Stmts: []ast.Stmt{s.Init, loop}}
+	}
+	return loop
+}
+
+// ifStmt parses a conditional statement after the if keyword.
+//
+//	ifStmt → "if" "(" expression ")" statement ( "else" statement )?
+func (p *parser) ifStmt() ast.Stmt {
+	s := &ast.If{Keyword: pos(p.prev)}
+	lparen := p.expect(itemLeftParen, "'(' after 'if'")
+	s.Cond = p.expression()
+	p.expectClosing(itemRightParen, "')' after if condition", pos(lparen))
+	s.Then = p.statement()
+	if p.match(itemElse) {
+		s.Else = p.statement()
+	}
+	return s
+}
+
+// block parses a block after the opening brace.
+//
+//	block → "{" declaration* "}"
+func (p *parser) block() *ast.Block {
+	b := &ast.Block{Lbrace: pos(p.prev)}
+	for !p.check(itemRightBrace) && !p.check(itemEOF) {
+		if s := p.declaration(); s != nil {
+			b.Stmts = append(b.Stmts, s)
+		}
+	}
+	b.Rbrace = pos(p.expectClosing(itemRightBrace, "'}' after block", b.Lbrace))
+	return b
+}
+
+// expression parses an expression, which may be a comma expression.
+// The operands of a comma expression are evaluated from left to
+// right, and its value is the value of the right operand.
+//
+//	expression → assignment ( "," assignment )*
+func (p *parser) expression() ast.Expr {
+	e := p.assignment()
+	for p.match(itemComma) {
+		op := p.prev
+		e = &ast.Binary{X: e, OpPos: pos(op), Op: op.Val(), Y: p.assignment()}
+	}
+	return e
+}
+
+// assignment parses an expression other than a comma expression.
+//
+//	assignment → ( call "." )? IDENTIFIER "=" assignment | conditional
+func (p *parser) assignment() ast.Expr {
+	p.enter()
+	defer p.leave()
+
+	e := p.conditional()
+	if p.match(itemEqual) {
+		value := p.assignment()
+		switch e := e.(type) {
+		case *ast.Variable:
+			return &ast.Assign{Name: e.Name, Value: value, Depth: e.Depth}
+		case *ast.Get:
+			return &ast.Set{X: e.X, Name: e.Name, Value: value}
+		}
+		// The parser is not confused, so keep going.
+		p.reportSpan(e.Pos(), e.End(), CodeSyntaxError, "invalid assignment target")
+	}
+	return e
+}
+
+// conditional parses a conditional expression. As in C, the then
+// branch may be any expression and the operator is right associative.
+//
+//	conditional → binary ( "?" expression ":" conditional )?
+func (p *parser) conditional() ast.Expr {
+	p.enter()
+	defer p.leave()
+
+	e := p.binary(precLowest)
+	if !p.match(itemQuestion) {
+		return e
+	}
+	c := &ast.Conditional{Cond: e, Question: pos(p.prev)}
+	c.Then = p.expression()
+	c.Colon = pos(p.expectClosing(itemColon, "':' after then branch of conditional expression", c.Question))
+	c.Else = p.conditional()
+	return c
+}
+
+// Precedence levels of the binary operators, from the loosest to the
+// tightest.
+const (
+	precOr = iota + 1
+	precAnd
+	precEquality
+	precComparison
+	precTerm
+	precFactor
+
+	precLowest = precOr
+)
+
+// infixOp describes a binary operator.
+type infixOp struct {
+	prec    int  // precedence level; higher levels bind tighter.
+	right   bool // whether the operator is right associative.
+	logical bool // whether the operator short-circuits.
+}
+
+// infixOps is the precedence table of the binary operators. Adding an
+// entry is enough to make the parser accept a new operator, as long as
+// the lexer produces its token. Logical operators produce
+// [ast.Logical] nodes and the rest [ast.Binary] nodes.
+var infixOps = map[itemType]infixOp{
+	itemOr:           {prec: precOr, logical: true},
+	itemAnd:          {prec: precAnd, logical: true},
+	itemBangEqual:    {prec: precEquality},
+	itemEqualEqual:   {prec: precEquality},
+	itemGreater:      {prec: precComparison},
+	itemGreaterEqual: {prec: precComparison},
+	itemLess:         {prec: precComparison},
+	itemLessEqual:    {prec: precComparison},
+	itemMinus:        {prec: precTerm},
+	itemPlus:         {prec: precTerm},
+	itemSlash:        {prec: precFactor},
+	itemStar:         {prec: precFactor},
+}
+
+// prefixOps are the unary operators.
+var prefixOps = []itemType{itemBang, itemMinus}
+
+// binary parses a sequence of unary expressions joined by binary
+// operators of precedence level minPrec or higher, by precedence
+// climbing on infixOps. With the default table, it is equivalent to
+// these rules of the grammar:
+//
+//	logic_or   → logic_and ( "or" logic_and )*
+//	logic_and  → equality ( "and" equality )*
+//	equality   → comparison ( ( "!=" | "==" ) comparison )*
+//	comparison → term ( ( ">" | ">=" | "<" | "<=" ) term )*
+//	term       → factor ( ( "-" | "+" ) factor )*
+//	factor     → unary ( ( "/" | "*" ) unary )*
+func (p *parser) binary(minPrec int) ast.Expr {
+	e := p.unary()
+	for {
+		op, ok := infixOps[p.tok.typ]
+		if !ok || op.prec < minPrec {
+			return e
+		}
+		p.next()
+		tok := p.prev
+		next := op.prec + 1
+		if op.right {
+			next = op.prec
+		}
+		y := p.binary(next)
+		if op.logical {
+			e = &ast.Logical{X: e, OpPos: pos(tok), Op: tok.Val(), Y: y}
+		} else {
+			e = &ast.Binary{X: e, OpPos: pos(tok), Op: tok.Val(), Y: y}
+		}
+	}
+}
+
+// unary parses a unary expression.
+//
+//	unary → ( "!" | "-" ) unary | call
+func (p *parser) unary() ast.Expr {
+	p.enter()
+	defer p.leave()
+
+	if p.match(prefixOps...) {
+		op := p.prev
+		return &ast.Unary{OpPos: pos(op), Op: op.Val(), X: p.unary()}
+	}
+	return p.call()
+}
+
+// call parses calls and property accesses.
+//
+//	call      → primary ( "(" arguments? ")" | "." IDENTIFIER )*
+//	arguments → assignment ( "," assignment )*
+func (p *parser) call() ast.Expr {
+	e := p.primary()
+	for {
+		switch {
+		case p.match(itemLeftParen):
+			c := &ast.Call{Callee: e, Lparen: pos(p.prev)}
+			if !p.check(itemRightParen) {
+				for {
+					if len(c.Args) == maxArgs {
+						p.report(p.tok, CodeTooManyArgs, "too many arguments (max %d)", maxArgs)
+					}
+					c.Args = append(c.Args, p.assignment())
+					if !p.match(itemComma) {
+						break
+					}
+				}
+			}
+			c.Rparen = pos(p.expectClosing(itemRightParen, "')' after arguments", c.Lparen))
+			e = c
+		case p.match(itemDot):
+			e = &ast.Get{X: e, Name: ident(p.expect(itemIdentifier, "property name after '.'"))}
+		default:
+			return e
+		}
+	}
+}
+
+// primary parses a primary expression.
+//
+//	primary → "true" | "false" | "nil" | "this" | NUMBER | STRING
+//	        | IDENTIFIER | "(" expression ")" | "super" "." IDENTIFIER
+func (p *parser) primary() ast.Expr {
+	tok := p.tok
+	switch {
+	case p.match(itemFalse):
+		return p.literal(tok, false)
+	case p.match(itemTrue):
+		return p.literal(tok, true)
+	case p.match(itemNil):
+		return p.literal(tok, nil)
+	case p.match(itemNumber):
+		return p.literal(tok, p.number(tok))
+	case p.match(itemString, itemRawString):
+		return p.literal(tok, tok.lit)
+	case p.match(itemThis):
+		return &ast.This{Keyword: pos(tok), Depth: -1}
+	case p.match(itemSuper):
+		p.expect(itemDot, "'.' after 'super'")
+		return &ast.Super{Keyword: pos(tok), Method: ident(p.expect(itemIdentifier, "superclass method name")), Depth: -1}
+	case p.match(itemIdentifier):
+		return &ast.Variable{Name: ident(tok), Depth: -1}
+	case p.match(itemLeftParen):
+		e := p.expression()
+		rparen := p.expectClosing(itemRightParen, "')' after expression", pos(tok))
+		return &ast.Grouping{Lparen: pos(tok), X: e, Rparen: pos(rparen)}
+	}
+	p.errorf(tok, "expected expression, found %s", describe(tok))
+	panic("unreachable")
+}
+
+// literal returns the literal expression of the item it, whose value
+// is v.
+func (p *parser) literal(it item, v any) *ast.Literal {
+	return &ast.Literal{ValuePos: pos(it), ValueEnd: p.end(it), Value: v, Raw: it.Val()}
+}
+
+// number returns the value of the number literal it.
+func (p *parser) number(it item) float64 {
+	if it.base == 10 {
+		f, err := strconv.ParseFloat(it.lit, 64)
+		if err != nil {
+			// Out of range.
+			p.errorf(it, "invalid number %s", it.Val())
+		}
+		return f
+	}
+	n, err := strconv.ParseUint(it.lit[2:], it.base, 64)
+	if err != nil {
+		p.errorf(it, "invalid number %s", it.Val())
+	}
+	return float64(n)
+}
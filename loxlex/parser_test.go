@@ -0,0 +1,590 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// sexprs returns the S-expressions of stmts, one per line.
+func sexprs(stmts []ast.Stmt) string {
+	lines := make([]string, len(stmts))
+	for i, s := range stmts {
+		lines[i] = sexpr(s)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParseStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Empty", "", ""},
+		{"Expression", "1 + 2;", "(; (+ 1 2))"},
+		{"Print", `print "hi";`, `(print "hi")`},
+		{"Var", "var a;", "(var a)"},
+		{"VarInit", "var a = 1 * 2;", "(var a = (* 1 2))"},
+		{"Assign", "a = b = c;", "(; (= a (= b c)))"},
+		{"Block", "{ var a = 1; print a; }", "(block (var a = 1) (print a))"},
+		{"EmptyBlock", "{}", "(block)"},
+		{"NestedBlocks", "{ { print 1; } print 2; }", "(block (block (print 1)) (print 2))"},
+		{"Several", "var a = 1;\nprint a;\na = 2;", "(var a = 1)\n(print a)\n(; (= a 2))"},
+		{"Precedence", "print -123 * (45.67);", "(print (* (- 123) (group 45.67)))"},
+		{"Associativity", "print 1 - 2 - 3;", "(print (- (- 1 2) 3))"},
+		{"Logical", "print a or b and !c == d;", "(print (or a (and b (== (! c) d))))"},
+		{"Literals", "print nil; print true; print false; print 0x1F;", "(print nil)\n(print true)\n(print false)\n(print 31)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, diags := parse(tt.input)
+			if len(diags) > 0 {
+				t.Fatalf("unexpected diagnostics: %v", diags)
+			}
+			if got := sexprs(stmts); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string   // S-expressions of the statements parsed.
+		diags []string // diagnostics reported.
+	}{
+		{
+			"MissingSemicolon",
+			"print 1\nprint 2;",
+			"",
+			[]string{`2:1: error[LOX1001]: expected ';' after value, found "print"`},
+		},
+		{
+			"MissingVariableName",
+			"var = 1;\nprint 2;",
+			"(print 2)",
+			[]string{`1:5: error[LOX1001]: expected variable name, found "="`},
+		},
+		{
+			"UnclosedBlock",
+			"{ print 1;",
+			"",
+			[]string{`1:11: error[LOX1001]: expected '}' after block, found end of file`},
+		},
+		{
+			"InvalidAssignmentTarget",
+			"a + b = 1;",
+			"(; (+ a b))",
+			[]string{`1:1: error[LOX1001]: invalid assignment target`},
+		},
+		{
+			"Several",
+			"var 1;\nprint ;\nprint 3;",
+			"(print 3)",
+			[]string{
+				`1:5: error[LOX1001]: expected variable name, found "1"`,
+				`2:7: error[LOX1001]: expected expression, found ";"`,
+			},
+		},
+		{
+			"MissingSuperclass",
+			"class A < {}\nprint 2;",
+			"(print 2)",
+			[]string{`1:11: error[LOX1001]: expected superclass name, found "{"`},
+		},
+		{
+			"FunInClass",
+			"class A { fun f() {} }\nprint 2;",
+			"(print 2)",
+			[]string{`1:11: error[LOX1001]: expected method name, found "fun"`},
+		},
+		{
+			"BareSuper",
+			"print super;",
+			"",
+			[]string{`1:12: error[LOX1001]: expected '.' after 'super', found ";"`},
+		},
+		{
+			"LexicalError",
+			"print @;\nprint 2;",
+			"(print 2)",
+			[]string{`1:7: error[LOX0002]: unexpected character: @`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, diags := parse(tt.input)
+			if got := sexprs(stmts); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			var got []string
+			for _, d := range diags {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.diags, "\n") {
+				t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.diags, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseFor(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string // S-expression of the desugared loop.
+		keepFor string // S-expression with KeepFor.
+	}{
+		{
+			"for (var i = 0; i < 3; i = i + 1) print i;",
+			"(block (var i = 0) (while (< i 3) (block (print i) (; (= i (+ i 1))))))",
+			"(for (var i = 0) (< i 3) (= i (+ i 1)) (print i))",
+		},
+		{
+			"for (i = 0; i < 3;) {}",
+			"(block (; (= i 0)) (while (< i 3) (block)))",
+			"(for (; (= i 0)) (< i 3) () (block))",
+		},
+		{
+			"for (;;) print 1;",
+			"(while true (print 1))",
+			"(for () () () (print 1))",
+		},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		if got := sexprs(stmts); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.input, got, tt.want)
+		}
+		stmts, _ = ParserOptions{KeepFor: true}.parse(tt.input)
+		if got := sexprs(stmts); got != tt.keepFor {
+			t.Errorf("%q: with keepFor, got %s, want %s", tt.input, got, tt.keepFor)
+		}
+	}
+}
+
+func TestParseFunctions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"fun f() {}", "(fun f ())"},
+		{"fun add(a, b) { return a + b; }", "(fun add (a b) (return (+ a b)))"},
+		{"fun f() { return; }", "(fun f () (return))"},
+		{"f();", "(; (call f))"},
+		{"f(1)(2, 3);", "(; (call (call f 1) 2 3))"},
+		{"print -f(a, b + 1);", "(print (- (call f a (+ b 1))))"},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		if got := sexprs(stmts); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseTooManyArgs(t *testing.T) {
+	names := func(n int) string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("a%d", i)
+		}
+		return strings.Join(list, ", ")
+	}
+	tests := []struct {
+		input string
+		want  int // number of errors.
+	}{
+		{"fun f(" + names(maxArgs) + ") {}", 0},
+		{"fun f(" + names(maxArgs+1) + ") {}", 1},
+		{"f(" + names(maxArgs) + ");", 0},
+		{"f(" + names(maxArgs+2) + ");", 1},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(stmts) != 1 {
+			t.Errorf("%.20q...: got %d statements, want 1", tt.input, len(stmts))
+		}
+		if len(diags) != tt.want {
+			t.Errorf("%.20q...: got %d diagnostics, want %d", tt.input, len(diags), tt.want)
+		}
+		for _, d := range diags {
+			if d.Code != CodeTooManyArgs {
+				t.Errorf("%.20q...: unexpected diagnostic: %v", tt.input, d)
+			}
+		}
+	}
+}
+
+func TestParseTooDeep(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int // number of errors.
+	}{
+		{strings.Repeat("(", maxDepth/4) + "1" + strings.Repeat(")", maxDepth/4) + ";", 0},
+		{strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth) + ";", 1},
+		{strings.Repeat("{", 10*maxDepth), 1},
+		{strings.Repeat("-", 10*maxDepth) + "1;", 1},
+		{strings.Repeat("a = ", 10*maxDepth) + "1;", 1},
+		{strings.Repeat("fun f() {", 10*maxDepth), 1},
+		{strings.Repeat("if (a) ", 10*maxDepth) + "print 1;", 1},
+	}
+	for _, tt := range tests {
+		_, diags := parse(tt.input)
+		if len(diags) != tt.want {
+			t.Errorf("%.20q...: got %d diagnostics, want %d", tt.input, len(diags), tt.want)
+		}
+		for _, d := range diags {
+			if d.Code != CodeTooDeep {
+				t.Errorf("%.20q...: unexpected diagnostic: %v", tt.input, d)
+			}
+		}
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"class A {}", "(class A)"},
+		{"class B < A {}", "(class B < A)"},
+		{
+			"class A { init(x) { this.x = x; } get() { return this.x; } }",
+			"(class A (fun init (x) (; (= this x x))) (fun get () (return (. this x))))",
+		},
+		{
+			"class B < A { get() { return super.get() + 1; } }",
+			"(class B < A (fun get () (return (+ (call (super get)) 1))))",
+		},
+		{"a.b.c = d.e();", "(; (= (. a b) c (call (. d e))))"},
+		{"A().b = 1;", "(; (= (call A) b 1))"},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		if got := sexprs(stmts); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseConditionalAndComma(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a ? b : c;", "(; (?: a b c))"},
+		{"a ? b : c ? d : e;", "(; (?: a b (?: c d e)))"},
+		{"a ? b ? c : d : e;", "(; (?: a (?: b c d) e))"},
+		{"a or b ? c and d : e;", "(; (?: (or a b) (and c d) e))"},
+		{"x = a ? b : c;", "(; (= x (?: a b c)))"},
+		{"a, b, c;", "(; (, (, a b) c))"},
+		{"a = 1, b = 2;", "(; (, (= a 1) (= b 2)))"},
+		{"a ? b, c : d;", "(; (?: a (, b c) d))"},
+		{"f(a, b);", "(; (call f a b))"},
+		{"f((a, b));", "(; (call f (group (, a b))))"},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		if got := sexprs(stmts); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string // source code of the spans of the statements.
+	}{
+		{"print 1 + 2 ;", []string{"print 1 + 2 ;"}},
+		{"var a; var b = 1;", []string{"var a;", "var b = 1;"}},
+		{"return; return a.b;", []string{"return;", "return a.b;"}},
+		{" f(a) (b) ;", []string{"f(a) (b) ;"}},
+		{"a = \"x\ny\";", []string{"a = \"x\ny\";"}},
+		{"a = \"x\r\ny\";\rb = \"x\ry\";", []string{"a = \"x\r\ny\";", "b = \"x\ry\";"}},
+		{"if (a) print 1; else { }", []string{"if (a) print 1; else { }"}},
+		{"fun f() {} class A { m() {} }", []string{"fun f() {}", "class A { m() {} }"}},
+		{"for (;;) a;", []string{"for (;;) a;"}},
+		{"for (var i = 0; i < 1; i = i + 1) a;", []string{"for (var i = 0; i < 1; i = i + 1) a;"}},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		var got []string
+		for _, s := range stmts {
+			got = append(got, tt.input[s.Pos().Offset:s.End().Offset])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%q: got spans %q, want %q", tt.input, got, tt.want)
+		}
+
+		// The lines and columns of all positions match their
+		// offsets. "\r\n" and lone "\r" end lines too.
+		input := strings.NewReplacer("\r\n", " \n", "\r", "\n").Replace(tt.input)
+		for _, s := range stmts {
+			ast.Inspect(s, func(n ast.Node) bool {
+				if n == nil {
+					return false
+				}
+				for _, p := range []ast.Pos{n.Pos(), n.End()} {
+					if !p.IsValid() {
+						continue
+					}
+					line := strings.Count(input[:p.Offset], "\n") + 1
+					col := p.Offset - strings.LastIndexByte(input[:p.Offset], '\n')
+					if p.Line != line || p.Col != col {
+						t.Errorf("%q: %T at offset %d is at %v, want %d:%d", tt.input, n, p.Offset, p, line, col)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Add("for (var i = 0; i < 3; i = i + 1) { if (i) print i; else return; }")
+	f.Add("fun f(a, b) { return a ? b : (a, b); } f(1, 2).x = !-nil;")
+	f.Add(strings.Repeat("(", 2*maxDepth) + "1" + strings.Repeat(")", 2*maxDepth) + ";")
+	f.Add(strings.Repeat("{", 2*maxDepth))
+	f.Fuzz(func(t *testing.T, input string) {
+		// The parser neither panics nor overflows the stack, whatever
+		// the input.
+		stmts, diags := ParserOptions{KeepFor: true}.parse(input)
+		if len(diags) > 0 {
+			return
+		}
+
+		// The program printed from its syntax tree has the same
+		// tokens as the input, and the same syntax tree.
+		var sb strings.Builder
+		for _, s := range stmts {
+			sb.WriteString(loxSource(s))
+			sb.WriteByte('\n')
+		}
+		output := sb.String()
+		if got, want := tokens(output), tokens(input); !slices.Equal(got, want) {
+			t.Fatalf("printed program %q has tokens %q, want %q", output, got, want)
+		}
+		stmts2, diags := ParserOptions{KeepFor: true}.parse(output)
+		if len(diags) > 0 {
+			t.Fatalf("printed program %q: unexpected diagnostics: %v", output, diags)
+		}
+		if got, want := sexprs(stmts2), sexprs(stmts); got != want {
+			t.Fatalf("printed program %q is parsed as:\n%s\nwant:\n%s", output, got, want)
+		}
+	})
+}
+
+// tokens returns the type and the text of the items of input up to
+// the end of file.
+func tokens(input string) []string {
+	var toks []string
+	for it := range Lex(input) {
+		if it.typ == itemEOF {
+			break
+		}
+		toks = append(toks, fmt.Sprintf("%v %s", it.typ, it.Val()))
+	}
+	return toks
+}
+
+// loxSource returns the Lox source code of n, with the tokens
+// separated by spaces.
+func loxSource(n ast.Node) string {
+	var parts []string
+	var add func(...any)
+	add = func(args ...any) {
+		for _, a := range args {
+			switch a := a.(type) {
+			case string:
+				parts = append(parts, a)
+			case *ast.Ident:
+				parts = append(parts, a.Name)
+			case ast.Node:
+				parts = append(parts, loxSource(a))
+			default:
+				panic(fmt.Sprintf("unexpected part %T", a))
+			}
+		}
+	}
+
+	switch n := n.(type) {
+	case *ast.Assign:
+		add(n.Name, "=", n.Value)
+	case *ast.Binary:
+		add(n.X, n.Op, n.Y)
+	case *ast.Call:
+		add(n.Callee, "(")
+		for i, a := range n.Args {
+			if i > 0 {
+				add(",")
+			}
+			add(a)
+		}
+		add(")")
+	case *ast.Conditional:
+		add(n.Cond, "?", n.Then, ":", n.Else)
+	case *ast.Get:
+		add(n.X, ".", n.Name)
+	case *ast.Grouping:
+		add("(", n.X, ")")
+	case *ast.Literal:
+		add(n.Raw)
+	case *ast.Logical:
+		add(n.X, n.Op, n.Y)
+	case *ast.Set:
+		add(n.X, ".", n.Name, "=", n.Value)
+	case *ast.Super:
+		add("super", ".", n.Method)
+	case *ast.This:
+		add("this")
+	case *ast.Unary:
+		add(n.Op, n.X)
+	case *ast.Variable:
+		add(n.Name)
+
+	case *ast.Block:
+		add("{")
+		for _, s := range n.Stmts {
+			add(s)
+		}
+		add("}")
+	case *ast.Class:
+		add("class", n.Name)
+		if n.Superclass != nil {
+			add("<", n.Superclass)
+		}
+		add("{")
+		for _, m := range n.Methods {
+			add(m.Name, "(")
+			for i, p := range m.Params {
+				if i > 0 {
+					add(",")
+				}
+				add(p)
+			}
+			add(")", m.Body)
+		}
+		add("}")
+	case *ast.Expression:
+		add(n.X, ";")
+	case *ast.For:
+		add("for", "(")
+		if n.Init != nil {
+			add(n.Init)
+		} else {
+			add(";")
+		}
+		if n.Cond != nil {
+			add(n.Cond)
+		}
+		add(";")
+		if n.Incr != nil {
+			add(n.Incr)
+		}
+		add(")", n.Body)
+	case *ast.Fun:
+		add("fun", n.Name, "(")
+		for i, p := range n.Params {
+			if i > 0 {
+				add(",")
+			}
+			add(p)
+		}
+		add(")", n.Body)
+	case *ast.If:
+		add("if", "(", n.Cond, ")", n.Then)
+		if n.Else != nil {
+			add("else", n.Else)
+		}
+	case *ast.Print:
+		add("print", n.X, ";")
+	case *ast.Return:
+		add("return")
+		if n.Value != nil {
+			add(n.Value)
+		}
+		add(";")
+	case *ast.Var:
+		add("var", n.Name)
+		if n.Init != nil {
+			add("=", n.Init)
+		}
+		add(";")
+	case *ast.While:
+		add("while", "(", n.Cond, ")", n.Body)
+	default:
+		panic(fmt.Sprintf("unexpected node %T", n))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseInterning(t *testing.T) {
+	const input = "var a; fun f(a) { return a.a; } class A < a { a() { super.a(); } } a = f(a);"
+
+	// names returns the names of the identifiers of stmts.
+	names := func(stmts []ast.Stmt) []string {
+		var names []string
+		for _, s := range stmts {
+			ast.Inspect(s, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == "a" {
+					names = append(names, id.Name)
+				}
+				return n != nil
+			})
+		}
+		return names
+	}
+
+	stmts, diags := parse(input)
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	got := names(stmts)
+	if len(got) != 9 {
+		t.Fatalf("got %d identifiers named a, want 9", len(got))
+	}
+	for i, name := range got {
+		if unsafe.StringData(name) != unsafe.StringData(got[0]) {
+			t.Errorf("identifier %d does not share the storage of the first one", i)
+		}
+	}
+
+	// The table of the caller is used.
+	in := NewInterner()
+	stmts, _ = parse(input, withInterning(in))
+	c, ok := in.Lookup("a")
+	if !ok {
+		t.Fatal("a was not interned in the table of the caller")
+	}
+	for i, name := range names(stmts) {
+		if unsafe.StringData(name) != unsafe.StringData(c) {
+			t.Errorf("identifier %d does not share the storage of the table", i)
+		}
+	}
+}
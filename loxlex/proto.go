@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// This file implements the protocol buffer wire format of the
+// messages defined in token.proto by hand, so loxlex does not depend
+// on the protobuf runtime.
+
+// Protocol buffer wire types.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// Field numbers of the Token message.
+const (
+	tokenType     = 1
+	tokenValue    = 2
+	tokenLiteral  = 3
+	tokenBase     = 4
+	tokenLine     = 5
+	tokenCol      = 6
+	tokenStart    = 7
+	tokenEnd      = 8
+	tokenCode     = 9
+	tokenLeading  = 10
+	tokenTrailing = 11
+)
+
+// Field numbers of the Trivia message.
+const (
+	triviaKindField  = 1
+	triviaValueField = 2
+	triviaStartField = 3
+)
+
+// streamTokens is the field number of TokenStream.tokens.
+const streamTokens = 1
+
+// errBadProto is returned when unmarshaling malformed messages.
+var errBadProto = errors.New("malformed protocol buffer")
+
+// marshalTokens returns the TokenStream message holding items.
+func marshalTokens(items []item) []byte {
+	var b, tok []byte
+	for _, it := range items {
+		tok = appendToken(tok[:0], it)
+		b = appendBytesField(b, streamTokens, tok)
+	}
+	return b
+}
+
+// appendToken appends the Token message corresponding to it to b.
+func appendToken(b []byte, it item) []byte {
+	var val, code string
+	if it.typ == itemError {
+		var lerr *LexError
+		if errors.As(it.err, &lerr) {
+			code = codes[lerr.Kind]
+		}
+	} else {
+		val = it.Val()
+	}
+	b = appendStringField(b, tokenType, it.typ.String())
+	b = appendStringField(b, tokenValue, val)
+	b = appendStringField(b, tokenLiteral, it.lit)
+	b = appendVarintField(b, tokenBase, uint64(it.base))
+	b = appendVarintField(b, tokenLine, uint64(it.line))
+	b = appendVarintField(b, tokenCol, uint64(it.col))
+	b = appendVarintField(b, tokenStart, uint64(it.start))
+	b = appendVarintField(b, tokenEnd, uint64(it.end))
+	b = appendStringField(b, tokenCode, code)
+	for _, t := range it.lead {
+		b = appendBytesField(b, tokenLeading, appendTriviaMsg(nil, t))
+	}
+	for _, t := range it.trail {
+		b = appendBytesField(b, tokenTrailing, appendTriviaMsg(nil, t))
+	}
+	return b
+}
+
+// appendTriviaMsg appends the Trivia message corresponding to t to b.
+func appendTriviaMsg(b []byte, t trivia) []byte {
+	b = appendVarintField(b, triviaKindField, uint64(t.kind))
+	b = appendStringField(b, triviaValueField, t.val)
+	return appendVarintField(b, triviaStartField, uint64(t.start))
+}
+
+// appendVarintField appends a varint field to b. Following proto3,
+// zero values are omitted.
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendStringField appends a string field to b. Following proto3,
+// empty strings are omitted.
+func appendStringField(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireLen)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendBytesField appends a length-delimited field to b, even if it
+// is empty, as it is used for embedded messages.
+func appendBytesField(b []byte, num int, p []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireLen)
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+// unmarshalTokens returns the items of the TokenStream message b.
+// Unknown fields are skipped.
+func unmarshalTokens(b []byte) ([]item, error) {
+	var items []item
+	err := walkFields(b, func(num, typ int, v uint64, p []byte) error {
+		if num != streamTokens || typ != wireLen {
+			return nil
+		}
+		it, err := unmarshalToken(p)
+		if err != nil {
+			return err
+		}
+		items = append(items, it)
+		return nil
+	})
+	return items, err
+}
+
+// unmarshalToken returns the item corresponding to the Token message
+// b.
+func unmarshalToken(b []byte) (item, error) {
+	var (
+		it        item
+		typ       string
+		val, code string
+	)
+	err := walkFields(b, func(num, wt int, v uint64, p []byte) error {
+		switch {
+		case wt == wireLen && num == tokenType:
+			typ = string(p)
+		case wt == wireLen && num == tokenValue:
+			val = string(p)
+		case wt == wireLen && num == tokenLiteral:
+			it.lit = string(p)
+		case wt == wireVarint && num == tokenBase:
+			it.base = int(v)
+		case wt == wireVarint && num == tokenLine:
+			it.line = int(v)
+		case wt == wireVarint && num == tokenCol:
+			it.col = int(v)
+		case wt == wireVarint && num == tokenStart:
+			it.start = int(v)
+		case wt == wireVarint && num == tokenEnd:
+			it.end = int(v)
+		case wt == wireLen && num == tokenCode:
+			code = string(p)
+		case wt == wireLen && (num == tokenLeading || num == tokenTrailing):
+			t, err := unmarshalTrivia(p)
+			if err != nil {
+				return err
+			}
+			if num == tokenLeading {
+				it.lead = append(it.lead, t)
+			} else {
+				it.trail = append(it.trail, t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return item{}, err
+	}
+
+	t, ok := itemTypeByName(typ)
+	if !ok {
+		return item{}, fmt.Errorf("%w: unknown token type %q", errBadProto, typ)
+	}
+	it.typ = t
+	if it.typ == itemError {
+		it.err = &LexError{
+			Kind: errorKind(code),
+			Pos:  Position{Offset: it.start, Line: it.line, Col: it.col},
+			Err:  errors.New(it.lit),
+		}
+	} else {
+		if it.end < it.start || len(val) != it.end-it.start {
+			return item{}, fmt.Errorf("%w: value does not match its span", errBadProto)
+		}
+		it.src = &source{text: val, off: it.start}
+	}
+	return it, nil
+}
+
+// unmarshalTrivia returns the trivia corresponding to the Trivia
+// message b.
+func unmarshalTrivia(b []byte) (trivia, error) {
+	var t trivia
+	err := walkFields(b, func(num, wt int, v uint64, p []byte) error {
+		switch {
+		case wt == wireVarint && num == triviaKindField:
+			t.kind = triviaKind(v)
+		case wt == wireLen && num == triviaValueField:
+			t.val = string(p)
+		case wt == wireVarint && num == triviaStartField:
+			t.start = int(v)
+		}
+		return nil
+	})
+	return t, err
+}
+
+// walkFields calls fn for every field of the message b. v is the
+// value of varint and fixed-size fields and p is the payload of
+// length-delimited fields.
+func walkFields(b []byte, fn func(num, wt int, v uint64, p []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("%w: bad tag", errBadProto)
+		}
+		b = b[n:]
+		num, wt := int(tag>>3), int(tag&7)
+		if num == 0 {
+			return fmt.Errorf("%w: bad field number", errBadProto)
+		}
+
+		var (
+			v uint64
+			p []byte
+		)
+		switch wt {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return fmt.Errorf("%w: bad varint", errBadProto)
+			}
+		case wireI64:
+			if len(b) < 8 {
+				return fmt.Errorf("%w: truncated field", errBadProto)
+			}
+			v, n = binary.LittleEndian.Uint64(b), 8
+		case wireLen:
+			var l uint64
+			l, n = binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return fmt.Errorf("%w: bad length", errBadProto)
+			}
+			p = b[n : n+int(l)]
+			n += int(l)
+		case wireI32:
+			if len(b) < 4 {
+				return fmt.Errorf("%w: truncated field", errBadProto)
+			}
+			v, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		default:
+			return fmt.Errorf("%w: unsupported wire type %d", errBadProto, wt)
+		}
+		b = b[n:]
+
+		if err := fn(num, wt, v, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itemTypeByName returns the item type with the given name.
+func itemTypeByName(name string) (itemType, bool) {
+	for t := itemError; t <= itemEOF; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
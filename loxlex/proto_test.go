@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	opts := []option{withTrivia(), withRecovery(), withInterpolation(), withDocComments()}
+	for _, input := range binaryInputs {
+		items := slices.Collect(Lex(input, opts...))
+		got, err := unmarshalTokens(marshalTokens(items))
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if want := fullDescs(items); !slices.Equal(fullDescs(got), want) {
+			t.Errorf("%q: got items\n%q\nwant\n%q", input, fullDescs(got), want)
+		}
+		for i := range min(len(got), len(items)) {
+			if items[i].typ != itemError {
+				continue
+			}
+			if g, w := got[i].err.Error(), items[i].err.Error(); g != w {
+				t.Errorf("%q: item %d: got error %q, want %q", input, i, g, w)
+			}
+		}
+	}
+}
+
+func TestProtoUnknownFields(t *testing.T) {
+	items := slices.Collect(Lex("var a;"))
+	var b []byte
+	b = appendVarintField(b, 15, 42)
+	b = binary.AppendUvarint(b, 16<<3|wireI64)
+	b = binary.LittleEndian.AppendUint64(b, 1)
+	b = binary.AppendUvarint(b, 17<<3|wireI32)
+	b = binary.LittleEndian.AppendUint32(b, 1)
+	b = appendStringField(b, 18, "unknown")
+	b = append(b, marshalTokens(items)...)
+	b = appendVarintField(b, streamTokens, 1) // wrong wire type
+	got, err := unmarshalTokens(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fullDescs(items); !slices.Equal(fullDescs(got), want) {
+		t.Errorf("got items %q, want %q", fullDescs(got), want)
+	}
+}
+
+func TestProtoMalformed(t *testing.T) {
+	// token returns a TokenStream with a single Token made of fields.
+	token := func(fields ...[]byte) string {
+		return string(appendBytesField(nil, streamTokens, bytes.Join(fields, nil)))
+	}
+	ident := appendStringField(nil, tokenType, itemIdentifier.String())
+	tests := []struct {
+		name string
+		b    string
+	}{
+		{"TruncatedTag", "\x80"},
+		{"FieldZero", "\x00\x01"},
+		{"TruncatedVarint", "\x08\x80"},
+		{"TruncatedI64", "\x09\x01\x02"},
+		{"TruncatedI32", "\x0d\x01\x02"},
+		{"LengthPastEnd", "\x0a\x05abc"},
+		{"HugeLength", "\x0a\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01"},
+		{"StartGroup", "\x0b"},
+		{"UnknownType", token(appendStringField(nil, tokenType, "Bogus"))},
+		{"MissingType", token(appendStringField(nil, tokenValue, "a"))},
+		{"ValueSpan", token(ident, appendStringField(nil, tokenValue, "ab"), appendVarintField(nil, tokenEnd, 3))},
+		{"EndBeforeStart", token(ident, appendVarintField(nil, tokenStart, 3), appendVarintField(nil, tokenEnd, 1))},
+		{"BadTokenField", token(ident, []byte("\x80"))},
+		{"BadTrivia", token(ident, appendBytesField(nil, tokenLeading, []byte("\x08")))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := unmarshalTokens([]byte(tt.b))
+			if !errors.Is(err, errBadProto) {
+				t.Errorf("got items %q and error %v, want %v", fullDescs(items), err, errBadProto)
+			}
+		})
+	}
+}
+
+func FuzzUnmarshalTokens(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(marshalTokens(slices.Collect(Lex(seed, withTrivia(), withRecovery()))))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		items, err := unmarshalTokens(b)
+		if err != nil {
+			return
+		}
+		// Valid messages survive a round trip, except for the
+		// unknown fields, which are dropped.
+		m := marshalTokens(items)
+		got, err := unmarshalTokens(m)
+		if err != nil {
+			t.Fatalf("unmarshaling %q: %v", m, err)
+		}
+		if !bytes.Equal(marshalTokens(got), m) {
+			t.Errorf("got items %q, want %q", fullDescs(got), fullDescs(items))
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+// edit describes a change to the input: the del bytes at offset off
+// are replaced with ins.
+type edit struct {
+	off int    // Offset of the change.
+	del int    // Number of bytes deleted.
+	ins string // Inserted text.
+}
+
+// apply returns the result of applying e to input.
+func (e edit) apply(input string) string {
+	return input[:e.off] + e.ins + input[e.off+e.del:]
+}
+
+// relexMargin is the number of items before an edit that are lexed
+// again, because the lookahead of the lexer makes them depend on the
+// input that follows them.
+const relexMargin = 2
+
+// relex returns the input resulting from applying e to input and its
+// items, given items, the items of input. Instead of lexing the whole
+// input again, it lexes only the region affected by the edit: it
+// restarts a few items before the edit and stops as soon as it
+// produces an item that matches one of the old items following the
+// edit. The rest of the old items are reused, with their positions
+// shifted. The same options used to lex input must be provided.
+func relex(input string, items []item, e edit, opts ...option) (string, []item) {
+	text := e.apply(input)
+	depths := interpDepths(items)
+
+	// Restart after an item preceding the edit.
+	k := -1
+	for i, it := range items {
+		if it.trailEnd() >= e.off {
+			break
+		}
+		k = i
+	}
+	k = max(k-relexMargin, -1)
+	for k >= 0 && !atBoundary(items, depths, k+1) {
+		k--
+	}
+
+	lx := NewLexer(text, opts...)
+	src := &source{text: text}
+	lx.l.src = src
+	out := make([]item, 0, len(items))
+	if k >= 0 {
+		out = append(out, items[:k+1]...)
+		lx.seek(items[k])
+	}
+
+	// Old items starting after the edit, whose positions must be
+	// shifted by delta bytes, can be reused once the lexer produces
+	// the same item in the same state.
+	delta := len(e.ins) - e.del
+	j := k + 1
+	depth := 0
+	for {
+		it := lx.Next()
+		out = append(out, it)
+		if it.typ == itemEOF || lx.done() {
+			return text, out
+		}
+		n := len(out) - 1
+		sync := it.start-delta >= e.off+e.del &&
+			(n == k+1 || boundary(out[n-1], depth, it))
+		depth += interpDelta(it)
+		if !sync {
+			continue
+		}
+		for j < len(items) && items[j].start < it.start-delta {
+			j++
+		}
+		if j == len(items) {
+			continue
+		}
+		old := items[j]
+		if old.start != it.start-delta || old.end != it.end-delta ||
+			old.typ != it.typ || old.col != it.col || !atBoundary(items, depths, j) {
+			continue
+		}
+		n = len(out)
+		out = append(out, items[j+1:]...)
+		lines := it.line - old.line
+		for i := n; i < len(out); i++ {
+			shift(&out[i], src, delta, lines)
+		}
+		return text, out
+	}
+}
+
+// interpDepths returns the number of embedded expressions of
+// interpolated strings that are open after each item.
+func interpDepths(items []item) []int {
+	depths := make([]int, len(items))
+	depth := 0
+	for i, it := range items {
+		depth += interpDelta(it)
+		depths[i] = depth
+	}
+	return depths
+}
+
+// interpDelta returns how the number of open embedded expressions
+// changes after it.
+func interpDelta(it item) int {
+	switch it.typ {
+	case itemInterpStart:
+		return 1
+	case itemInterpEnd:
+		return -1
+	}
+	return 0
+}
+
+// atBoundary reports whether the lexer is scanning plain code, and
+// not an interpolated string, right before items[i].
+func atBoundary(items []item, depths []int, i int) bool {
+	if i == 0 || i == len(items) {
+		return true
+	}
+	return boundary(items[i-1], depths[i-1], items[i])
+}
+
+// boundary reports whether the lexer is scanning plain code between
+// the consecutive items prev and next, given the number of embedded
+// expressions open after prev.
+func boundary(prev item, depth int, next item) bool {
+	// After "}" the lexer continues scanning the string, and "${"
+	// is always preceded by a piece of the string.
+	return depth == 0 && prev.typ != itemInterpEnd && next.typ != itemInterpStart
+}
+
+// shift moves it delta bytes and lines lines forward and makes it
+// take its value from src.
+func shift(it *item, src *source, delta, lines int) {
+	it.src = src
+	it.start += delta
+	it.end += delta
+	it.line += lines
+	it.lead = shiftTrivia(it.lead, delta)
+	it.trail = shiftTrivia(it.trail, delta)
+	if le, ok := it.err.(*LexError); ok {
+		shifted := *le
+		shifted.Pos.Offset += delta
+		shifted.Pos.Line += lines
+		it.err = &shifted
+	}
+}
+
+// shiftTrivia returns a copy of trs moved delta bytes forward.
+func shiftTrivia(trs []trivia, delta int) []trivia {
+	if trs == nil {
+		return nil
+	}
+	shifted := make([]trivia, len(trs))
+	for i, tr := range trs {
+		tr.start += delta
+		shifted[i] = tr
+	}
+	return shifted
+}
+
+// trailEnd returns the offset just past the end of the trailing
+// trivia of it.
+func (it item) trailEnd() int {
+	if n := len(it.trail); n > 0 {
+		tr := it.trail[n-1]
+		return tr.start + len(tr.val)
+	}
+	return it.end
+}
+
+// seek moves the lexer past it, an item of its input, and its
+// trailing trivia, in the state that follows it.
+func (lx *Lexer) seek(it item) {
+	l := lx.l
+	end := it.trailEnd()
+	line, col := it.line, it.col
+	for i := it.start; i < end; i++ {
+		if c := l.input[i]; c == '\n' || c == '\r' && (i+1 == len(l.input) || l.input[i+1] != '\n') {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	l.pos = end
+	l.line = line
+	l.lineStart = end - col + 1
+	l.ignore()
+	lx.state = lexCode
+}
+
+// done reports whether the scan has finished and all the items have
+// been returned.
+func (lx *Lexer) done() bool {
+	return len(lx.queue) == 0 && (lx.state == nil || lx.l.halt)
+}
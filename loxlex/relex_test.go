@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fullDesc describes every field of it, so that items can be compared
+// regardless of how their values are stored.
+func fullDesc(it item) string {
+	desc := fmt.Sprintf("%s %s lit=%q base=%d", itemDesc(it), itemSpan(it), it.lit, it.base)
+	for _, tr := range it.lead {
+		desc += fmt.Sprintf(" lead(%d %d %q)", tr.kind, tr.start, tr.val)
+	}
+	for _, tr := range it.trail {
+		desc += fmt.Sprintf(" trail(%d %d %q)", tr.kind, tr.start, tr.val)
+	}
+	return desc
+}
+
+// fullDescs returns the descriptions of items.
+func fullDescs(items []item) []string {
+	descs := make([]string, len(items))
+	for i, it := range items {
+		descs[i] = fullDesc(it)
+	}
+	return descs
+}
+
+func TestRelex(t *testing.T) {
+	const input = "var s = \"hello\"; // greeting\nprint s + name;\n/* end */"
+	at := func(sub string) int {
+		i := strings.Index(input, sub)
+		if i < 0 {
+			panic("no " + sub + " in input")
+		}
+		return i
+	}
+	tests := []struct {
+		name string
+		e    edit
+	}{
+		{"InsertInString", edit{off: at("llo"), ins: "XY"}},
+		{"DeleteInString", edit{off: at("ell"), del: 2}},
+		{"InsertQuoteInString", edit{off: at("llo"), ins: `"`}},
+		{"DeleteClosingQuote", edit{off: at(`"; //`), del: 1}},
+		{"InsertInComment", edit{off: at("greeting"), ins: "a "}},
+		{"DeleteInComment", edit{off: at("greeting"), del: 3}},
+		{"DeleteCommentStart", edit{off: at("// greeting"), del: 1}},
+		{"OpenBlockComment", edit{off: at("print"), ins: "/*"}},
+		{"InsertInIdentifier", edit{off: at("ame"), ins: "ick"}},
+		{"DeleteInIdentifier", edit{off: at("ame"), del: 2}},
+		{"SplitIdentifier", edit{off: at("ame"), ins: " "}},
+		{"JoinTokens", edit{off: at(" + name"), del: 3}},
+		{"InsertNewline", edit{off: at("print"), ins: "\n\n"}},
+		{"DeleteNewline", edit{off: at("\nprint"), del: 1}},
+		{"InsertAtStart", edit{off: 0, ins: "x; "}},
+		{"DeleteAtStart", edit{off: 0, del: 4}},
+		{"InsertAtEnd", edit{off: len(input), ins: "\nprint 1;"}},
+		{"DeleteAtEnd", edit{off: len(input) - 4, del: 4}},
+		{"ReplaceAll", edit{off: 0, del: len(input), ins: "1 2"}},
+		{"InsertInEmpty", edit{off: 0, ins: "a"}},
+	}
+	for _, opts := range []struct {
+		name string
+		opts []option
+	}{
+		{"Default", nil},
+		{"Trivia", []option{withTrivia()}},
+		{"Recovery", []option{withRecovery(), withComments()}},
+	} {
+		for _, tt := range tests {
+			t.Run(opts.name+"/"+tt.name, func(t *testing.T) {
+				in := input
+				if tt.name == "InsertInEmpty" {
+					in = ""
+				}
+				items := slices.Collect(Lex(in, opts.opts...))
+				text, got := relex(in, items, tt.e, opts.opts...)
+				if want := tt.e.apply(in); text != want {
+					t.Fatalf("got text %q, want %q", text, want)
+				}
+				want := slices.Collect(Lex(text, opts.opts...))
+				if g, w := fullDescs(got), fullDescs(want); !slices.Equal(g, w) {
+					t.Errorf("edited text %q:\ngot:\n%s\nwant:\n%s", text, strings.Join(g, "\n"), strings.Join(w, "\n"))
+				}
+			})
+		}
+	}
+}
+
+func TestRelexInterpolation(t *testing.T) {
+	const input = `print "a${b + "c${d}"}e" + f;`
+	for i := 0; i <= len(input); i++ {
+		for _, e := range []edit{{off: i, ins: "x"}, {off: i, ins: "}"}, {off: i, ins: `"`}, {off: i, del: min(1, len(input)-i)}} {
+			items := slices.Collect(Lex(input, withInterpolation()))
+			text, got := relex(input, items, e, withInterpolation())
+			want := slices.Collect(Lex(text, withInterpolation()))
+			if g, w := fullDescs(got), fullDescs(want); !slices.Equal(g, w) {
+				t.Errorf("edited text %q:\ngot:\n%s\nwant:\n%s", text, strings.Join(g, "\n"), strings.Join(w, "\n"))
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxHistory is the number of lines kept in the history of the REPL.
+const maxHistory = 1000
+
+// replHelp describes the commands of the REPL.
+const replHelp = `Type Lox code to see its tokens. Commands:
+  :history  list the previous lines
+  !!        lex the previous line again
+  !N        lex line N of the history again
+  :help     show this help
+  :quit     exit (also Ctrl-D)
+`
+
+// repl runs an interactive session that prints the tokens of every
+// line typed by the user. The lines are kept in a history, saved in
+// the file .loxlex_history of the home directory, and can be recalled
+// with !! and !N. It returns the exit status of the command.
+func repl() int {
+	hist := newHistory()
+	out := bufio.NewWriter(os.Stdout)
+	p, err := newOutput(out, false, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	fmt.Fprint(out, "loxlex REPL; type :help for help.\n")
+	sc := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(out, "> ")
+		out.Flush()
+		if !sc.Scan() {
+			break
+		}
+		line := sc.Text()
+		switch cmd := strings.TrimSpace(line); {
+		case cmd == "":
+			continue
+		case cmd == ":quit" || cmd == ":q":
+			return 0
+		case cmd == ":help" || cmd == ":h":
+			fmt.Fprint(out, replHelp)
+			continue
+		case cmd == ":history":
+			for i, l := range hist.lines {
+				fmt.Fprintf(out, "%4d  %s\n", i+1, l)
+			}
+			continue
+		case cmd == "!!" || len(cmd) > 1 && cmd[0] == '!' && strings.Trim(cmd[1:], "0123456789") == "":
+			l, err := hist.recall(cmd[1:])
+			if err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			line = l
+			fmt.Fprintf(out, "%s\n", line)
+		}
+		hist.add(line)
+
+		p.start("<repl>")
+		cl := StartLexer(line, lexerOptions()...)
+		for it := range cl.Items() {
+			p.print(it)
+		}
+		out.Flush()
+		for _, d := range cl.Diagnostics() {
+			fmt.Fprintf(os.Stderr, "%v\n", d)
+		}
+	}
+	fmt.Fprintln(out)
+	p.close()
+	return 0
+}
+
+// history is the history of the REPL.
+type history struct {
+	lines []string
+	file  string // file where the history is saved, if any.
+	saved int    // number of lines in file.
+}
+
+// newHistory returns the history saved by previous sessions.
+func newHistory() *history {
+	h := &history{}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return h
+	}
+	h.file = filepath.Join(home, ".loxlex_history")
+	if b, err := os.ReadFile(h.file); err == nil {
+		h.lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		if len(h.lines) == 1 && h.lines[0] == "" {
+			h.lines = nil
+		}
+	}
+	h.saved = len(h.lines)
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+	}
+	return h
+}
+
+// add appends line to the history and to the history file. The file
+// is rewritten with the lines kept in memory when it gets longer than
+// maxHistory, so that it does not grow without bound.
+func (h *history) add(line string) {
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[1:]
+	}
+	if h.file == "" {
+		return
+	}
+	if h.saved >= maxHistory {
+		data := strings.Join(h.lines, "\n") + "\n"
+		if err := os.WriteFile(h.file, []byte(data), 0o600); err == nil {
+			h.saved = len(h.lines)
+		}
+		return
+	}
+	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	if _, err := fmt.Fprintln(f, line); err == nil {
+		h.saved++
+	}
+	f.Close()
+}
+
+// recall returns the line of the history referred to by ref, which is
+// "!" for the previous line or the number of a line.
+func (h *history) recall(ref string) (string, error) {
+	if len(h.lines) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	if ref == "!" {
+		return h.lines[len(h.lines)-1], nil
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil || n < 1 || n > len(h.lines) {
+		return "", fmt.Errorf("no line %q in history", ref)
+	}
+	return h.lines[n-1], nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestHistoryTrim checks that the history file is rewritten with the
+// lines kept in memory once it gets longer than maxHistory.
+func TestHistoryTrim(t *testing.T) {
+	h := &history{file: filepath.Join(t.TempDir(), "history")}
+	for i := range maxHistory + 10 {
+		h.add(strconv.Itoa(i))
+	}
+	b, err := os.ReadFile(h.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(got) != maxHistory {
+		t.Fatalf("got %d lines in the history file, want %d", len(got), maxHistory)
+	}
+	if first, last := got[0], got[len(got)-1]; first != "10" || last != strconv.Itoa(maxHistory+9) {
+		t.Errorf("got lines %s to %s, want 10 to %d", first, last, maxHistory+9)
+	}
+	if len(h.lines) != maxHistory || h.lines[0] != "10" {
+		t.Errorf("got %d lines in memory starting at %s, want %d starting at 10", len(h.lines), h.lines[0], maxHistory)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// Diagnostic codes of the resolver.
+const (
+	CodeReadInInitializer = "LOX2001"
+	CodeUnusedVariable    = "LOX2002"
+	CodeUnusedFunction    = "LOX2003"
+	CodeInvalidReturn     = "LOX2004"
+	CodeThisOutsideClass  = "LOX2005"
+	CodeInvalidSuper      = "LOX2006"
+	CodeDuplicateDecl     = "LOX2007"
+	CodeSelfInheritance   = "LOX2008"
+)
+
+// resolver is the static pass of chapter 11 of Crafting Interpreters,
+// which resolves the references to local variables. It stores in
+// every reference the number of scopes between it and the declaration
+// of the variable, so that the interpreter finds the variable that was
+// in scope where it is used, even if a closure outlives the scope.
+//
+// Variables that are not found in the local scopes are global, which
+// are resolved dynamically and may be declared after their use.
+//
+// The resolver reports the errors that the book reports at compile
+// time, such as a return statement outside of a function or two
+// declarations of a local variable in the same scope. It also warns
+// about the local variables that are never read and the functions that
+// are never called or otherwise referred to, except from their own
+// bodies.
+type resolver struct {
+	scopes     []map[string]*binding // local scopes, innermost last.
+	functions  []*binding            // functions being resolved, innermost last; nil for methods.
+	fun        funKind               // kind of the innermost function being resolved.
+	class      classKind             // kind of the innermost class being resolved.
+	globalFuns []*binding            // functions declared in the global scope.
+	globalRefs map[string]bool       // names of the global variables referred to.
+	diags      []Diagnostic
+}
+
+// funKind is the kind of a function.
+type funKind int
+
+const (
+	funNone funKind = iota // Not in a function.
+	funFunction
+	funMethod
+	funInitializer // The init method of a class.
+)
+
+// classKind is the kind of a class.
+type classKind int
+
+const (
+	classNone classKind = iota // Not in a class.
+	classClass
+	classSubclass // Class with a superclass.
+)
+
+// binding is a name declared in a scope.
+type binding struct {
+	name    *ast.Ident // nil for this and super.
+	kind    bindingKind
+	defined bool // whether it can be read.
+	used    bool // whether it has been read.
+}
+
+// bindingKind is the kind of declaration of a binding.
+type bindingKind int
+
+const (
+	bindVar bindingKind = iota
+	bindParam
+	bindFun
+	bindClass
+	bindImplicit // this and super.
+)
+
+// resolve resolves the variables of the program made of stmts, whose
+// syntax trees are modified in place, and returns the errors and
+// warnings found.
+func resolve(stmts []ast.Stmt) []Diagnostic {
+	r := &resolver{globalRefs: map[string]bool{}}
+	r.stmts(stmts)
+	// Global functions may be called before their declaration.
+	for _, b := range r.globalFuns {
+		if !r.globalRefs[b.name.Name] {
+			r.warnUnused(b)
+		}
+	}
+	slices.SortStableFunc(r.diags, func(a, b Diagnostic) int {
+		return cmp.Compare(a.Pos.Offset, b.Pos.Offset)
+	})
+	return r.diags
+}
+
+// report reports a diagnostic with the given severity and code that
+// spans the source code of n.
+func (r *resolver) report(n ast.Node, sev Severity, code, format string, args ...any) {
+	r.diags = append(r.diags, Diagnostic{
+		Pos:      position(n.Pos()),
+		End:      position(n.End()),
+		Severity: sev,
+		Code:     code,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
+// errorf reports an error with the given code that spans the source
+// code of n.
+func (r *resolver) errorf(n ast.Node, code, format string, args ...any) {
+	r.report(n, SeverityError, code, format, args...)
+}
+
+// warnUnused reports that the variable or function b is not used.
+// Other bindings, such as parameters, are not reported.
+func (r *resolver) warnUnused(b *binding) {
+	switch b.kind {
+	case bindVar:
+		r.report(b.name, SeverityWarning, CodeUnusedVariable, "local variable %s declared and not used", b.name.Name)
+	case bindFun:
+		r.report(b.name, SeverityWarning, CodeUnusedFunction, "function %s declared and not used", b.name.Name)
+	}
+}
+
+// beginScope starts a local scope.
+func (r *resolver) beginScope() {
+	r.scopes = append(r.scopes, map[string]*binding{})
+}
+
+// endScope ends the innermost local scope and reports its unused
+// bindings.
+func (r *resolver) endScope() {
+	for _, b := range r.scopes[len(r.scopes)-1] {
+		if !b.used && b.name != nil {
+			r.warnUnused(b)
+		}
+	}
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare adds name to the innermost local scope, or to the global
+// functions if there is none, without defining it, so that it cannot
+// be read yet. It returns the binding of name. Unlike global ones,
+// local variables cannot be declared twice in the same scope.
+func (r *resolver) declare(name *ast.Ident, kind bindingKind) *binding {
+	b := &binding{name: name, kind: kind}
+	if len(r.scopes) > 0 {
+		scope := r.scopes[len(r.scopes)-1]
+		if prev, ok := scope[name.Name]; ok && prev.name != nil {
+			r.errorf(name, CodeDuplicateDecl, "%s already declared in this scope", name.Name)
+			d := &r.diags[len(r.diags)-1]
+			d.Notes = append(d.Notes, Note{
+				Pos: position(prev.name.Pos()),
+				End: position(prev.name.End()),
+				Msg: "previous declaration",
+			})
+		}
+		scope[name.Name] = b
+	} else if kind == bindFun {
+		r.globalFuns = append(r.globalFuns, b)
+	}
+	return b
+}
+
+// define marks b as defined.
+func (r *resolver) define(b *binding) {
+	b.defined = true
+}
+
+// bindImplicit binds this or super in the innermost local scope.
+func (r *resolver) bindImplicit(name string) {
+	r.scopes[len(r.scopes)-1][name] = &binding{kind: bindImplicit, defined: true}
+}
+
+// local returns the number of scopes between the innermost local scope
+// and the one that declares name, and the binding of name, or -1 and
+// nil if name is global.
+func (r *resolver) local(name string) (int, *binding) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if b, ok := r.scopes[i][name]; ok {
+			return len(r.scopes) - 1 - i, b
+		}
+	}
+	return -1, nil
+}
+
+// read resolves a reference that reads name and returns its depth.
+// The references from the body of a function to itself do not count
+// as uses of the function.
+func (r *resolver) read(name string) int {
+	depth, b := r.local(name)
+	if b == nil {
+		recursive := slices.ContainsFunc(r.functions, func(f *binding) bool {
+			return f != nil && f.name.Name == name && slices.Contains(r.globalFuns, f)
+		})
+		if !recursive {
+			r.globalRefs[name] = true
+		}
+	} else if !slices.Contains(r.functions, b) {
+		b.used = true
+	}
+	return depth
+}
+
+// stmts resolves a list of statements.
+func (r *resolver) stmts(stmts []ast.Stmt) {
+	for _, s := range stmts {
+		r.stmt(s)
+	}
+}
+
+// stmt resolves the statement s.
+func (r *resolver) stmt(s ast.Stmt) {
+	switch s := s.(type) {
+	case *ast.Block:
+		r.beginScope()
+		r.stmts(s.Stmts)
+		r.endScope()
+	case *ast.Class:
+		enclosing := r.class
+		r.class = classClass
+		r.define(r.declare(s.Name, bindClass))
+		if s.Superclass != nil {
+			if s.Superclass.Name.Name == s.Name.Name {
+				r.errorf(s.Superclass, CodeSelfInheritance, "class %s inherits from itself", s.Name.Name)
+			}
+			r.class = classSubclass
+			r.expr(s.Superclass)
+			r.beginScope()
+			r.bindImplicit("super")
+		}
+		r.beginScope()
+		r.bindImplicit("this")
+		for _, m := range s.Methods {
+			kind := funMethod
+			if m.Name.Name == "init" {
+				kind = funInitializer
+			}
+			r.function(m, nil, kind)
+		}
+		r.endScope()
+		if s.Superclass != nil {
+			r.endScope()
+		}
+		r.class = enclosing
+	case *ast.Expression:
+		r.expr(s.X)
+	case *ast.For:
+		// As in the desugared loop, the variables declared by the
+		// initializer are in a scope of their own.
+		r.beginScope()
+		if s.Init != nil {
+			r.stmt(s.Init)
+		}
+		if s.Cond != nil {
+			r.expr(s.Cond)
+		}
+		if s.Incr != nil {
+			r.expr(s.Incr)
+		}
+		r.stmt(s.Body)
+		r.endScope()
+	case *ast.Fun:
+		// Functions can refer to themselves.
+		b := r.declare(s.Name, bindFun)
+		r.define(b)
+		r.function(s, b, funFunction)
+	case *ast.If:
+		r.expr(s.Cond)
+		r.stmt(s.Then)
+		if s.Else != nil {
+			r.stmt(s.Else)
+		}
+	case *ast.Print:
+		r.expr(s.X)
+	case *ast.Return:
+		if r.fun == funNone {
+			r.errorf(s, CodeInvalidReturn, "can't return from top-level code")
+		}
+		if s.Value != nil {
+			if r.fun == funInitializer {
+				r.errorf(s.Value, CodeInvalidReturn, "can't return a value from an initializer")
+			}
+			r.expr(s.Value)
+		}
+	case *ast.Var:
+		b := r.declare(s.Name, bindVar)
+		if s.Init != nil {
+			r.expr(s.Init)
+		}
+		r.define(b)
+	case *ast.While:
+		r.expr(s.Cond)
+		r.stmt(s.Body)
+	default:
+		panic(fmt.Sprintf("unexpected statement %T", s))
+	}
+}
+
+// function resolves the parameters and the body of the function or
+// method f of the given kind, which share a scope. b is the binding of
+// the function, or nil for methods.
+func (r *resolver) function(f *ast.Fun, b *binding, kind funKind) {
+	enclosing := r.fun
+	r.fun = kind
+	r.functions = append(r.functions, b)
+	r.beginScope()
+	for _, p := range f.Params {
+		r.define(r.declare(p, bindParam))
+	}
+	r.stmts(f.Body.Stmts)
+	r.endScope()
+	r.functions = r.functions[:len(r.functions)-1]
+	r.fun = enclosing
+}
+
+// expr resolves the expression e.
+func (r *resolver) expr(e ast.Expr) {
+	switch e := e.(type) {
+	case *ast.Assign:
+		// Assigning a variable does not use it.
+		r.expr(e.Value)
+		e.Depth, _ = r.local(e.Name.Name)
+	case *ast.Binary:
+		r.expr(e.X)
+		r.expr(e.Y)
+	case *ast.Call:
+		r.expr(e.Callee)
+		for _, a := range e.Args {
+			r.expr(a)
+		}
+	case *ast.Conditional:
+		r.expr(e.Cond)
+		r.expr(e.Then)
+		r.expr(e.Else)
+	case *ast.Get:
+		r.expr(e.X)
+	case *ast.Grouping:
+		r.expr(e.X)
+	case *ast.Literal:
+		// Nothing to do.
+	case *ast.Logical:
+		r.expr(e.X)
+		r.expr(e.Y)
+	case *ast.Set:
+		r.expr(e.Value)
+		r.expr(e.X)
+	case *ast.Super:
+		switch r.class {
+		case classNone:
+			r.errorf(e, CodeInvalidSuper, "can't use super outside of a class")
+		case classClass:
+			r.errorf(e, CodeInvalidSuper, "can't use super in a class with no superclass")
+		}
+		e.Depth = r.read("super")
+	case *ast.This:
+		if r.class == classNone {
+			r.errorf(e, CodeThisOutsideClass, "can't use this outside of a class")
+		}
+		e.Depth = r.read("this")
+	case *ast.Unary:
+		r.expr(e.X)
+	case *ast.Variable:
+		if len(r.scopes) > 0 {
+			if b, ok := r.scopes[len(r.scopes)-1][e.Name.Name]; ok && !b.defined {
+				r.errorf(e, CodeReadInInitializer, "can't read local variable %s in its own initializer", e.Name.Name)
+			}
+		}
+		e.Depth = r.read(e.Name.Name)
+	default:
+		panic(fmt.Sprintf("unexpected expression %T", e))
+	}
+}
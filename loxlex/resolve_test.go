@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// depths returns the depths of the references to variables in stmts,
+// in order, as name:depth.
+func depths(stmts []ast.Stmt) string {
+	var refs []string
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Assign:
+				refs = append(refs, fmt.Sprintf("%s=:%d", n.Name.Name, n.Depth))
+			case *ast.Super:
+				refs = append(refs, fmt.Sprintf("super:%d", n.Depth))
+			case *ast.This:
+				refs = append(refs, fmt.Sprintf("this:%d", n.Depth))
+			case *ast.Variable:
+				refs = append(refs, fmt.Sprintf("%s:%d", n.Name.Name, n.Depth))
+			}
+			return true
+		})
+	}
+	return strings.Join(refs, " ")
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"var a = 1; print a; a = 2;", "a:-1 a=:-1"},
+		{"{ var a; print a; { print a; a = 1; } }", "a:0 a:1 a=:1"},
+		{"var a; { var b = a; { var a = b; print a; } }", "a:-1 b:1 a:0"},
+		{"fun f(x) { return x + y; }", "x:0 y:-1"},
+		{"fun f() { fun g() { return f() + g(); } }", "f:-1 g:1"},
+		{"{ fun f() { return f; } }", "f:1"},
+		{"{ var i = 0; while (i < 3) { print i; i = i + 1; } }", "i:0 i:1 i=:1 i:1"},
+		{"{ for (var i = 0; i < 3; i = i + 1) print i; }", "i:0 i:1 i=:1 i:1"},
+		{"class A { m() { return this; } }", "this:1"},
+		{"class B < A { m() { fun f() { return super.m; } } }", "A:-1 super:3"},
+		{"{ class A {} var a = A(); }", "A:0"},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		for _, d := range resolve(stmts) {
+			if d.Severity == SeverityError {
+				t.Fatalf("%q: unexpected resolution error: %v", tt.input, d)
+			}
+		}
+		if got := depths(stmts); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestResolveDiagnostics(t *testing.T) {
+	tests := []struct {
+		input string
+		diags []string
+	}{
+		{"var a = a;", nil},
+		{"{ var a = a; print a; }", []string{"1:11: error[LOX2001]: can't read local variable a in its own initializer"}},
+		{"var a; { var a = -a + 1; print a; }", []string{"1:19: error[LOX2001]: can't read local variable a in its own initializer"}},
+		{"{ var a = 1; { var b = a; print b; } }", nil},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		var got []string
+		for _, d := range resolve(stmts) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.diags, "\n") {
+			t.Errorf("%q: got diagnostics:\n%s\nwant:\n%s", tt.input, strings.Join(got, "\n"), strings.Join(tt.diags, "\n"))
+		}
+	}
+}
+
+func TestResolveUnused(t *testing.T) {
+	tests := []struct {
+		input string
+		diags []string
+	}{
+		{"var a; fun f() {}", []string{"1:12: warning[LOX2003]: function f declared and not used"}},
+		{"f(); fun f() {}", nil},
+		{"fun f() { g(); } fun g() {} f();", nil},
+		{"fun f() { f(); }", []string{"1:5: warning[LOX2003]: function f declared and not used"}},
+		{"fun f(x) { return x; } print f;", nil},
+		{"fun f(x, y) {} f();", nil},
+		{
+			"{ var a; var b = 1; b = 2; var c; print c; }",
+			[]string{
+				"1:7: warning[LOX2002]: local variable a declared and not used",
+				"1:14: warning[LOX2002]: local variable b declared and not used",
+			},
+		},
+		{
+			"fun f() { fun g() { return g; } var h = 1; } f();",
+			[]string{
+				"1:15: warning[LOX2003]: function g declared and not used",
+				"1:37: warning[LOX2002]: local variable h declared and not used",
+			},
+		},
+		{"{ fun f() {} fun g() { f(); } g(); }", nil},
+		{"{ class A {} } class B { m() { return this; } }", nil},
+		{"{ for (var i = 0; i < 1;) {} }", nil},
+		{"{ for (var i = 0;;) {} }", []string{"1:12: warning[LOX2002]: local variable i declared and not used"}},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		var got []string
+		for _, d := range resolve(stmts) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.diags, "\n") {
+			t.Errorf("%q: got diagnostics:\n%s\nwant:\n%s", tt.input, strings.Join(got, "\n"), strings.Join(tt.diags, "\n"))
+		}
+	}
+}
+
+func TestResolveMisuse(t *testing.T) {
+	tests := []struct {
+		input string
+		diags []string
+	}{
+		{"return 1;", []string{"1:1: error[LOX2004]: can't return from top-level code"}},
+		{"fun f() { return 1; } f();", nil},
+		{
+			"class A { init() { return 1; } m() { return 2; } }",
+			[]string{"1:27: error[LOX2004]: can't return a value from an initializer"},
+		},
+		{"class A { init() { return; } }", nil},
+		{"print this;", []string{"1:7: error[LOX2005]: can't use this outside of a class"}},
+		{"fun f() { return this; } f();", []string{"1:18: error[LOX2005]: can't use this outside of a class"}},
+		{"class A { m() { fun f() { return this; } return f; } }", nil},
+		{"super.m();", []string{"1:1: error[LOX2006]: can't use super outside of a class"}},
+		{"class A { m() { super.m(); } }", []string{"1:17: error[LOX2006]: can't use super in a class with no superclass"}},
+		{"class B < A { m() { super.m(); } }", nil},
+		{"class A < A {}", []string{"1:11: error[LOX2008]: class A inherits from itself"}},
+		{"var a; var a; print a;", nil},
+		{
+			"{ var a = 1; var a = 2; print a; }",
+			[]string{"1:18: error[LOX2007]: a already declared in this scope"},
+		},
+		{"{ var a = 1; { var a = 2; print a; } print a; }", nil},
+		{"fun f(a, a) {} f();", []string{"1:10: error[LOX2007]: a already declared in this scope"}},
+		{"fun f(a) { var a; print a; } f();", []string{"1:16: error[LOX2007]: a already declared in this scope"}},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		var got []string
+		for _, d := range resolve(stmts) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.diags, "\n") {
+			t.Errorf("%q: got diagnostics:\n%s\nwant:\n%s", tt.input, strings.Join(got, "\n"), strings.Join(tt.diags, "\n"))
+		}
+	}
+}
+
+func TestResolveDuplicateNote(t *testing.T) {
+	stmts, _ := parse("{\n  var a;\n  var a;\n  print a;\n}")
+	diags := resolve(stmts)
+	if len(diags) != 1 || len(diags[0].Notes) != 1 {
+		t.Fatalf("got diagnostics %v, want one with a note", diags)
+	}
+	if n := diags[0].Notes[0]; n.Pos.String() != "2:7" || n.Msg != "previous declaration" {
+		t.Errorf("got note %q at %v, want %q at 2:7", n.Msg, n.Pos, "previous declaration")
+	}
+}
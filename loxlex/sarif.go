@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"unicode/utf8"
+)
+
+// sarifVersion is the version of the SARIF format written by
+// sarifPrinter.
+const sarifVersion = "2.1.0"
+
+// sarifSchema is the URI of the JSON schema of the SARIF format.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// The types below are the subset of the [SARIF] format used by
+// loxlex.
+//
+// [SARIF]: https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+
+	sarifPhysicalLocation struct {
+		ArtifactLocation *sarifArtifactLocation `json:"artifactLocation,omitempty"`
+		Region           sarifRegion            `json:"region"`
+	}
+
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine,omitempty"`
+		EndColumn   int `json:"endColumn,omitempty"`
+	}
+)
+
+// ruleDescriptions are the descriptions of the diagnostic codes that
+// do not correspond to lexical errors.
+var ruleDescriptions = map[string]string{
+	CodeBidiControl:   "bidirectional control character",
+	CodeConfusable:    "confusable character in identifier",
+	CodeInvisibleChar: "invisible character",
+	CodeSyntaxError:   "syntax error",
+	CodeTooManyArgs:   "too many arguments or parameters",
+	CodeTooDeep:       "nesting too deep",
+
+	CodeReadInInitializer: "local variable read in its own initializer",
+	CodeUnusedVariable:    "unused local variable",
+	CodeUnusedFunction:    "unused function",
+	CodeInvalidReturn:     "invalid return statement",
+	CodeThisOutsideClass:  "this outside of a class",
+	CodeInvalidSuper:      "invalid use of super",
+	CodeDuplicateDecl:     "duplicate declaration",
+	CodeSelfInheritance:   "class inherits from itself",
+	CodeUnreachable:       "unreachable code",
+}
+
+// ruleDescription returns the description of the diagnostic code.
+func ruleDescription(code string) string {
+	for kind, c := range codes {
+		if c == code {
+			return kind.Error()
+		}
+	}
+	return ruleDescriptions[code]
+}
+
+// diagPrinter is implemented by printers that print the diagnostics
+// of the inputs and not only their items.
+type diagPrinter interface {
+	printer
+
+	// diagnostics is called after the items of each input with its
+	// diagnostics.
+	diagnostics(ds []Diagnostic)
+}
+
+// sarifPrinter prints the diagnostics of all the inputs as a SARIF
+// log instead of the items, so they can be shown by code scanning
+// tools. The columns count Unicode code points, as declared by the
+// columnKind of the run, so they are computed from the source text.
+type sarifPrinter struct {
+	w       *bufio.Writer
+	name    string // name of the current input.
+	text    string // source text of the current input.
+	results []sarifResult
+}
+
+func newSARIFPrinter(w io.Writer) *sarifPrinter {
+	return &sarifPrinter{w: bufio.NewWriter(w)}
+}
+
+func (p *sarifPrinter) start(name string) error {
+	p.name, p.text = name, ""
+	return nil
+}
+
+func (p *sarifPrinter) source(text string) {
+	p.text = text
+}
+
+// column returns the column of pos in code points.
+func (p *sarifPrinter) column(pos Position) int {
+	start := pos.Offset - (pos.Col - 1)
+	if pos.Col < 1 || start < 0 || pos.Offset > len(p.text) {
+		return pos.Col
+	}
+	return utf8.RuneCountInString(p.text[start:pos.Offset]) + 1
+}
+
+func (p *sarifPrinter) print(it item) error {
+	return nil
+}
+
+func (p *sarifPrinter) diagnostics(ds []Diagnostic) {
+	var loc *sarifArtifactLocation
+	if p.name != displayName("-") {
+		uri := filepath.ToSlash(p.name)
+		if filepath.IsAbs(p.name) {
+			uri = (&url.URL{Scheme: "file", Path: uri}).String()
+		}
+		loc = &sarifArtifactLocation{URI: uri}
+	}
+	for _, d := range ds {
+		level := "error"
+		if d.Severity == SeverityWarning {
+			level = "warning"
+		}
+		r := sarifResult{
+			RuleID:  d.Code,
+			Level:   level,
+			Message: sarifMessage{Text: d.Msg},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: loc,
+					Region: sarifRegion{
+						StartLine:   d.Pos.Line,
+						StartColumn: p.column(d.Pos),
+						EndLine:     d.End.Line,
+						EndColumn:   p.column(d.End),
+					},
+				},
+			}},
+		}
+		p.results = append(p.results, r)
+	}
+}
+
+func (p *sarifPrinter) close() error {
+	var ids []string
+	for _, r := range p.results {
+		if !slices.Contains(ids, r.RuleID) {
+			ids = append(ids, r.RuleID)
+		}
+	}
+	slices.Sort(ids)
+	rules := make([]sarifRule, len(ids))
+	for i, id := range ids {
+		rules[i] = sarifRule{ID: id, ShortDescription: sarifMessage{Text: ruleDescription(id)}}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: sarifDriver{Name: "loxlex", Rules: rules}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    p.results,
+		}},
+	}
+	// Results must be an array, even if empty.
+	if log.Runs[0].Results == nil {
+		log.Runs[0].Results = []sarifResult{}
+	}
+	enc := json.NewEncoder(p.w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		return err
+	}
+	return p.w.Flush()
+}
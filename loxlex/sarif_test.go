@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestSARIFColumns checks that the columns of the results count code
+// points and not bytes.
+func TestSARIFColumns(t *testing.T) {
+	const input = "print \"é€\" @;"
+	lx := NewLexer(input, withRecovery())
+	for lx.Next().typ != itemEOF {
+	}
+
+	var buf bytes.Buffer
+	p := newSARIFPrinter(&buf)
+	if err := p.start("test.lox"); err != nil {
+		t.Fatal(err)
+	}
+	p.source(input)
+	p.diagnostics(lx.Diagnostics())
+	if err := p.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if got := log.Runs[0].ColumnKind; got != "unicodeCodePoints" {
+		t.Errorf("got column kind %q, want unicodeCodePoints", got)
+	}
+	if n := len(log.Runs[0].Results); n != 1 {
+		t.Fatalf("got %d results, want 1", n)
+	}
+	r := log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region
+	want := sarifRegion{StartLine: 1, StartColumn: 12, EndLine: 1, EndColumn: 13}
+	if r != want {
+		t.Errorf("got region %+v, want %+v", r, want)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file implements the detection of characters that can make the
+// source code look different from what the compiler sees, such as the
+// ones used by Trojan Source attacks. See https://trojansource.codes/.
+
+// isBidiControl returns whether r is a Unicode bidirectional control
+// character, which can reorder the text displayed by editors.
+func isBidiControl(r rune) bool {
+	switch r {
+	case '\u061C', // Arabic letter mark.
+		'\u200E', '\u200F', // Left-to-right and right-to-left marks.
+		'\u202A', '\u202B', '\u202C', '\u202D', '\u202E', // Embeddings and overrides.
+		'\u2066', '\u2067', '\u2068', '\u2069': // Isolates.
+		return true
+	}
+	return false
+}
+
+// confusables associates non-Latin letters with the Latin letters
+// they are easily mistaken for.
+var confusables = map[rune]rune{
+	// Cyrillic.
+	'\u0430': 'a', '\u0435': 'e', '\u043E': 'o', '\u0440': 'p',
+	'\u0441': 'c', '\u0443': 'y', '\u0445': 'x', '\u0456': 'i',
+	'\u0458': 'j', '\u0455': 's', '\u0501': 'd', '\u04BB': 'h',
+	'\u051B': 'q', '\u051D': 'w', '\u0410': 'A', '\u0412': 'B',
+	'\u0415': 'E', '\u041A': 'K', '\u041C': 'M', '\u041D': 'H',
+	'\u041E': 'O', '\u0420': 'P', '\u0421': 'C', '\u0422': 'T',
+	'\u0425': 'X', '\u0406': 'I', '\u0408': 'J', '\u0405': 'S',
+
+	// Greek.
+	'\u03BF': 'o', '\u03BD': 'v', '\u0391': 'A', '\u0392': 'B',
+	'\u0395': 'E', '\u0396': 'Z', '\u0397': 'H', '\u0399': 'I',
+	'\u039A': 'K', '\u039C': 'M', '\u039D': 'N', '\u039F': 'O',
+	'\u03A1': 'P', '\u03A4': 'T', '\u03A5': 'Y', '\u03A7': 'X',
+}
+
+// isInvisible returns whether r is a character with no visible
+// glyph, other than white space and bidirectional control characters,
+// which can make different strings look the same.
+func isInvisible(r rune) bool {
+	switch r {
+	case '\u00AD', // Soft hyphen.
+		'\u200B', '\u200C', '\u200D', // Zero width space, non-joiner and joiner.
+		'\u2060', '\u2061', '\u2062', '\u2063', '\u2064', // Word joiner and invisible operators.
+		'\uFEFF': // Zero width no-break space, a byte order mark only at the start of the input.
+		return true
+	}
+	return false
+}
+
+// checkHidden warns about the bidirectional control and invisible
+// characters of the pending input.
+func (l *lexer) checkHidden() {
+	if !l.opts.SecurityWarnings {
+		return
+	}
+	for i, r := range l.input[l.start:l.pos] {
+		pos := l.start + i
+		switch {
+		case isBidiControl(r):
+			l.warn(pos, pos+utf8.RuneLen(r), CodeBidiControl, "bidirectional control character %U", r)
+		case isInvisible(r):
+			l.warn(pos, pos+utf8.RuneLen(r), CodeInvisibleChar, "invisible character %U", r)
+		}
+	}
+}
+
+// checkConfusable warns if the pending input, an identifier, contains
+// letters easily mistaken for Latin letters, and it either contains
+// Latin letters too or is entirely made of such letters. Identifiers
+// legitimately written in other scripts are not reported.
+func (l *lexer) checkConfusable() {
+	if !l.opts.SecurityWarnings {
+		return
+	}
+	word := l.input[l.start:l.pos]
+	var (
+		first, look rune
+		latin       bool
+		others      bool
+	)
+	for _, r := range word {
+		if c, ok := confusables[r]; ok {
+			if first == 0 {
+				first, look = r, c
+			}
+			continue
+		}
+		switch {
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			latin = true
+		case unicode.IsLetter(r):
+			others = true
+		}
+	}
+	if first == 0 || others && !latin {
+		return
+	}
+	l.warn(l.start, l.pos, CodeConfusable, "identifier %q contains %U, which looks like %q", word, first, look)
+}
+
+// warn records a warning diagnostic of the given code for the input
+// between pos and end. Unlike errors, warnings are not passed back to
+// the client as items.
+func (l *lexer) warn(pos, end int, code, format string, args ...any) {
+	if l.halt {
+		return
+	}
+	l.diags = append(l.diags, Diagnostic{
+		Pos:      l.offsetPos(pos),
+		End:      l.offsetPos(end),
+		Severity: SeverityWarning,
+		Code:     code,
+		Msg:      fmt.Sprintf(l.opts.Messages.translate(format), args...),
+	})
+}
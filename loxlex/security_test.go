@@ -0,0 +1,84 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSecurityWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"BidiInString", "x = \"a\u202Eb\";", []string{
+			"1:7: warning[LOX0013]: bidirectional control character U+202E",
+		}},
+		{"BidiInLineComment", "x; // a\u2066b\u2069", []string{
+			"1:8: warning[LOX0013]: bidirectional control character U+2066",
+			"1:12: warning[LOX0013]: bidirectional control character U+2069",
+		}},
+		{"BidiInBlockComment", "/* a\n\u200Fb */", []string{
+			"2:1: warning[LOX0013]: bidirectional control character U+200F",
+		}},
+		{"BidiInRawString", "`\u061C`", []string{
+			"1:2: warning[LOX0013]: bidirectional control character U+061C",
+		}},
+		{"InvisibleInString", "\"secret\u200B\"", []string{
+			"1:8: warning[LOX0015]: invisible character U+200B",
+		}},
+		{"InvisibleInComment", "// a\u2060b\u00ADc", []string{
+			"1:5: warning[LOX0015]: invisible character U+2060",
+			"1:9: warning[LOX0015]: invisible character U+00AD",
+		}},
+		{"ZeroWidthNoBreakSpace", "\uFEFF\"a\uFEFFb\"", []string{
+			"1:3: warning[LOX0015]: invisible character U+FEFF",
+		}},
+		{"Confusable", "var p\u0430ss;", []string{
+			"1:5: warning[LOX0014]: identifier \"p\u0430ss\" contains U+0430, which looks like 'a'",
+		}},
+		{"OtherScript", "var \u043F\u0430\u0440\u043E\u043B\u044C;", nil},
+		{"Escapes", `"\u{202E}\u{200B}"`, nil},
+		{"Clean", "var a = \"b\"; // c", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lx := NewLexer(tt.input, withSecurityWarnings())
+			for lx.Next().typ != itemEOF {
+			}
+			var got []string
+			for _, d := range lx.Diagnostics() {
+				got = append(got, d.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+
+			// Without security warnings, nothing is reported.
+			lx = NewLexer(tt.input)
+			for lx.Next().typ != itemEOF {
+			}
+			if diags := lx.Diagnostics(); len(diags) > 0 {
+				t.Errorf("got diagnostics without security warnings: %v", diags)
+			}
+		})
+	}
+}
+
+func TestSecurityWarningsInterpolation(t *testing.T) {
+	lx := NewLexer("\"a\u202E${b}\u200Bc\"", withSecurityWarnings(), withInterpolation())
+	for lx.Next().typ != itemEOF {
+	}
+	var got []string
+	for _, d := range lx.Diagnostics() {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"1:3: warning[LOX0013]: bidirectional control character U+202E",
+		"1:10: warning[LOX0015]: invisible character U+200B",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
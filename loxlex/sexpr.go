@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// sexpr returns the representation of n as a Lisp-style S-expression,
+// such as (* (- 123) (group 45.67)), in the style of the AstPrinter of
+// Crafting Interpreters.
+func sexpr(n ast.Node) string {
+	switch n := n.(type) {
+	case ast.Expr:
+		return ast.VisitExpr(sexprPrinter{}, n)
+	case ast.Stmt:
+		return ast.VisitStmt(sexprPrinter{}, n)
+	}
+	panic(fmt.Sprintf("unexpected node %T", n))
+}
+
+// sexprPrinter is the visitor that prints S-expressions.
+type sexprPrinter struct{}
+
+var (
+	_ ast.ExprVisitor[string] = sexprPrinter{}
+	_ ast.StmtVisitor[string] = sexprPrinter{}
+)
+
+// parens returns the parts in parentheses, separated by spaces. Parts
+// are either strings or nodes.
+func (sp sexprPrinter) parens(parts ...any) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		switch p := p.(type) {
+		case string:
+			b.WriteString(p)
+		case ast.Node:
+			b.WriteString(sexpr(p))
+		default:
+			panic(fmt.Sprintf("unexpected part %T", p))
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func (sp sexprPrinter) VisitAssign(e *ast.Assign) string {
+	return sp.parens("=", e.Name.Name, e.Value)
+}
+
+func (sp sexprPrinter) VisitBinary(e *ast.Binary) string {
+	return sp.parens(e.Op, e.X, e.Y)
+}
+
+func (sp sexprPrinter) VisitCall(e *ast.Call) string {
+	parts := []any{"call", e.Callee}
+	for _, a := range e.Args {
+		parts = append(parts, a)
+	}
+	return sp.parens(parts...)
+}
+
+func (sp sexprPrinter) VisitConditional(e *ast.Conditional) string {
+	return sp.parens("?:", e.Cond, e.Then, e.Else)
+}
+
+func (sp sexprPrinter) VisitGet(e *ast.Get) string {
+	return sp.parens(".", e.X, e.Name.Name)
+}
+
+func (sp sexprPrinter) VisitGrouping(e *ast.Grouping) string {
+	return sp.parens("group", e.X)
+}
+
+func (sp sexprPrinter) VisitLiteral(e *ast.Literal) string {
+	return literalString(e.Value)
+}
+
+func (sp sexprPrinter) VisitLogical(e *ast.Logical) string {
+	return sp.parens(e.Op, e.X, e.Y)
+}
+
+func (sp sexprPrinter) VisitSet(e *ast.Set) string {
+	return sp.parens("=", e.X, e.Name.Name, e.Value)
+}
+
+func (sp sexprPrinter) VisitSuper(e *ast.Super) string {
+	return sp.parens("super", e.Method.Name)
+}
+
+func (sp sexprPrinter) VisitThis(e *ast.This) string {
+	return "this"
+}
+
+func (sp sexprPrinter) VisitUnary(e *ast.Unary) string {
+	return sp.parens(e.Op, e.X)
+}
+
+func (sp sexprPrinter) VisitVariable(e *ast.Variable) string {
+	return e.Name.Name
+}
+
+func (sp sexprPrinter) VisitBlock(s *ast.Block) string {
+	parts := []any{"block"}
+	for _, s := range s.Stmts {
+		parts = append(parts, s)
+	}
+	return sp.parens(parts...)
+}
+
+func (sp sexprPrinter) VisitClass(s *ast.Class) string {
+	parts := []any{"class", s.Name.Name}
+	if s.Superclass != nil {
+		parts = append(parts, "<", s.Superclass.Name.Name)
+	}
+	for _, m := range s.Methods {
+		parts = append(parts, m)
+	}
+	return sp.parens(parts...)
+}
+
+func (sp sexprPrinter) VisitExpression(s *ast.Expression) string {
+	return sp.parens(";", s.X)
+}
+
+func (sp sexprPrinter) VisitFor(s *ast.For) string {
+	// Missing clauses are written as ().
+	parts := []any{"for", "()", "()", "()", s.Body}
+	if s.Init != nil {
+		parts[1] = s.Init
+	}
+	if s.Cond != nil {
+		parts[2] = s.Cond
+	}
+	if s.Incr != nil {
+		parts[3] = s.Incr
+	}
+	return sp.parens(parts...)
+}
+
+func (sp sexprPrinter) VisitFun(s *ast.Fun) string {
+	params := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		params[i] = p.Name
+	}
+	parts := []any{"fun", s.Name.Name, "(" + strings.Join(params, " ") + ")"}
+	for _, s := range s.Body.Stmts {
+		parts = append(parts, s)
+	}
+	return sp.parens(parts...)
+}
+
+func (sp sexprPrinter) VisitIf(s *ast.If) string {
+	if s.Else != nil {
+		return sp.parens("if-else", s.Cond, s.Then, s.Else)
+	}
+	return sp.parens("if", s.Cond, s.Then)
+}
+
+func (sp sexprPrinter) VisitPrint(s *ast.Print) string {
+	return sp.parens("print", s.X)
+}
+
+func (sp sexprPrinter) VisitReturn(s *ast.Return) string {
+	if s.Value != nil {
+		return sp.parens("return", s.Value)
+	}
+	return sp.parens("return")
+}
+
+func (sp sexprPrinter) VisitVar(s *ast.Var) string {
+	if s.Init != nil {
+		return sp.parens("var", s.Name.Name, "=", s.Init)
+	}
+	return sp.parens("var", s.Name.Name)
+}
+
+func (sp sexprPrinter) VisitWhile(s *ast.While) string {
+	return sp.parens("while", s.Cond, s.Body)
+}
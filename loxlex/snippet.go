@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetLabel is a span of the source code marked in a snippet.
+type snippetLabel struct {
+	pos, end Position
+	primary  bool   // whether it is the span of the diagnostic.
+	msg      string // message printed after the mark, if any.
+}
+
+// writeSnippet writes the diagnostic d of the named input src to w,
+// followed by the source lines it refers to, in the style of the Rust
+// compiler. The span of the diagnostic is underlined with carets and
+// those of its notes with dashes followed by their messages:
+//
+//	prog.lox:2:1: error[LOX1001]: expected ')' after arguments, found "print"
+//	  |
+//	1 | print f(a, b
+//	  |        - opening '(' was here
+//	2 | print 2;
+//	  | ^^^^^
+func writeSnippet(w io.Writer, name, src string, d Diagnostic) {
+	fmt.Fprintf(w, "%s:%v\n", name, d)
+	if d.Pos.Line == 0 {
+		return
+	}
+
+	labels := []snippetLabel{{pos: d.Pos, end: d.End, primary: true}}
+	for _, n := range d.Notes {
+		labels = append(labels, snippetLabel{pos: n.Pos, end: n.End, msg: n.Msg})
+	}
+	slices.SortStableFunc(labels, func(a, b snippetLabel) int {
+		return cmp.Compare(a.pos.Offset, b.pos.Offset)
+	})
+
+	last := labels[len(labels)-1].pos.Line
+	width := len(strconv.Itoa(last))
+	gutter := func(s string) string {
+		return fmt.Sprintf("%*s | ", width, s)
+	}
+	fmt.Fprintf(w, "%s\n", strings.TrimRight(gutter(""), " "))
+	prev := 0
+	for _, l := range labels {
+		line := l.pos.Line
+		if line != prev {
+			if prev != 0 && line > prev+1 {
+				fmt.Fprintf(w, "%*s\n", width+1, "...")
+			}
+			text := sourceLine(src, l.pos.Offset)
+			fmt.Fprintf(w, "%s\n", strings.TrimRight(gutter(strconv.Itoa(line))+text, " "))
+			prev = line
+		}
+		fmt.Fprintf(w, "%s%s\n", gutter(""), labelMark(src, l))
+	}
+}
+
+// sourceLine returns the line of src that contains the byte at offset
+// off, without its line terminator.
+func sourceLine(src string, off int) string {
+	off = min(off, len(src))
+	start := strings.LastIndexByte(src[:off], '\n') + 1
+	end := len(src)
+	if i := strings.IndexByte(src[off:], '\n'); i >= 0 {
+		end = off + i
+	}
+	return strings.TrimSuffix(src[start:end], "\r")
+}
+
+// labelMark returns the line that marks the span of l under its source
+// line. Tabs are kept, so the mark is aligned whatever the tab width.
+func labelMark(src string, l snippetLabel) string {
+	line := sourceLine(src, l.pos.Offset)
+	col := l.pos.Col - 1
+
+	var b strings.Builder
+	for _, r := range line[:min(col, len(line))] {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	// Positions past the end of the line, such as the end of the
+	// input, are marked after it.
+	b.WriteString(strings.Repeat(" ", max(0, col-len(line))))
+
+	n := 1
+	if col < len(line) {
+		end := len(line)
+		if l.end.Line == l.pos.Line {
+			end = min(l.end.Col-1, len(line))
+		}
+		n = max(1, utf8.RuneCountInString(line[col:end]))
+	}
+	mark := "-"
+	if l.primary {
+		mark = "^"
+	}
+	b.WriteString(strings.Repeat(mark, n))
+	if l.msg != "" {
+		b.WriteString(" " + l.msg)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteSnippet(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			"print f(a, b\nprint 2;",
+			`prog.lox:2:1: error[LOX1001]: expected ')' after arguments, found "print"
+  |
+1 | print f(a, b
+  |        - opening '(' was here
+2 | print 2;
+  | ^^^^^
+`,
+		},
+		{
+			"\tprint (\"é\" + 2;",
+			"prog.lox:1:17: error[LOX1001]: expected ')' after expression, found \";\"\n" +
+				"  |\n" +
+				"1 | \tprint (\"é\" + 2;\n" +
+				"  | \t      - opening '(' was here\n" +
+				"  | \t              ^\n",
+		},
+		{
+			"{\n\n\nprint 1;\n",
+			`prog.lox:5:1: error[LOX1001]: expected '}' after block, found end of file
+  |
+1 | {
+  | - opening '{' was here
+...
+5 |
+  | ^
+`,
+		},
+	}
+	for _, tt := range tests {
+		_, diags := parse(tt.input)
+		if len(diags) != 1 {
+			t.Fatalf("%q: got %d diagnostics, want 1", tt.input, len(diags))
+		}
+		var sb strings.Builder
+		writeSnippet(&sb, "prog.lox", tt.input, diags[0])
+		if got := sb.String(); got != tt.want {
+			t.Errorf("%q: got:\n%s\nwant:\n%s", tt.input, got, tt.want)
+		}
+	}
+}
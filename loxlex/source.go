@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Source maps byte offsets of an input to line and column numbers,
+// similar to [go/token.File]. The lexer records the offsets of the
+// lines as it scans the input when a Source is provided through the
+// lexer options.
+type Source struct {
+	name  string
+	lines []int // offset of the first byte of each line.
+}
+
+// NewSource returns a Source for an input called name, such as a
+// file name.
+func NewSource(name string) *Source {
+	return &Source{name: name, lines: []int{0}}
+}
+
+// Name returns the name of the input.
+func (s *Source) Name() string {
+	return s.name
+}
+
+// AddLine records that a line starts at offset. Offsets must be
+// added in increasing order. Otherwise, they are ignored.
+func (s *Source) AddLine(offset int) {
+	if offset > s.lines[len(s.lines)-1] {
+		s.lines = append(s.lines, offset)
+	}
+}
+
+// LineCount returns the number of lines recorded so far.
+func (s *Source) LineCount() int {
+	return len(s.lines)
+}
+
+// LineStart returns the offset of the first byte of line, starting at
+// 1.
+func (s *Source) LineStart(line int) int {
+	if line < 1 || line > len(s.lines) {
+		panic(fmt.Sprintf("invalid line number %d (should be >= 1 and <= %d)", line, len(s.lines)))
+	}
+	return s.lines[line-1]
+}
+
+// Position returns the line and byte column, both starting at 1, of
+// offset. A byte order mark at the start of the input does not count
+// for columns, and offsets within it are in column 1.
+func (s *Source) Position(offset int) (line, col int) {
+	i := max(sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset })-1, 0)
+	return i + 1, max(offset-s.lines[i]+1, 1)
+}
+
+// Pos returns the [Position] of offset.
+func (s *Source) Pos(offset int) Position {
+	line, col := s.Position(offset)
+	return Position{Offset: offset, Line: line, Col: col}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// scanSource lexes input and returns the Source recorded by the lexer.
+func scanSource(input string) *Source {
+	src := NewSource("test.lox")
+	for range Lex(input, withSource(src), withRecovery()) {
+	}
+	return src
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lines []int    // LineStart of each line.
+		pos   []string // "offset line:col"
+	}{
+		{
+			name:  "empty",
+			input: "",
+			lines: []int{0},
+			pos:   []string{"0 1:1"},
+		},
+		{
+			name:  "no newline",
+			input: "x = 1;",
+			lines: []int{0},
+			pos:   []string{"0 1:1", "5 1:6", "6 1:7"},
+		},
+		{
+			name:  "LF",
+			input: "a\nbc\n\nd",
+			lines: []int{0, 2, 5, 6},
+			pos:   []string{"0 1:1", "1 1:2", "2 2:1", "4 2:3", "5 3:1", "6 4:1", "7 4:2"},
+		},
+		{
+			name:  "trailing newline",
+			input: "a\n",
+			lines: []int{0, 2},
+			pos:   []string{"1 1:2", "2 2:1"},
+		},
+		{
+			name:  "CRLF",
+			input: "a\r\nb\r\n",
+			lines: []int{0, 3, 6},
+			pos:   []string{"1 1:2", "2 1:3", "3 2:1", "6 3:1"},
+		},
+		{
+			name:  "CR",
+			input: "a\rb\r",
+			lines: []int{0, 2, 4},
+			pos:   []string{"1 1:2", "2 2:1", "4 3:1"},
+		},
+		{
+			name:  "multiline string",
+			input: "\"a\nb\" c",
+			lines: []int{0, 3},
+			pos:   []string{"2 1:3", "3 2:1", "6 2:4", "7 2:5"},
+		},
+		{
+			name:  "BOM",
+			input: "\uFEFFa\nb",
+			lines: []int{3, 5},
+			pos:   []string{"0 1:1", "3 1:1", "4 1:2", "5 2:1", "6 2:2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := scanSource(tt.input)
+			if got := src.Name(); got != "test.lox" {
+				t.Errorf("Name() = %q, want %q", got, "test.lox")
+			}
+			if got := src.LineCount(); got != len(tt.lines) {
+				t.Fatalf("LineCount() = %d, want %d", got, len(tt.lines))
+			}
+			for i, want := range tt.lines {
+				if got := src.LineStart(i + 1); got != want {
+					t.Errorf("LineStart(%d) = %d, want %d", i+1, got, want)
+				}
+			}
+			for _, want := range tt.pos {
+				var off int
+				fmt.Sscan(want, &off)
+				p := src.Pos(off)
+				if got := fmt.Sprintf("%d %d:%d", p.Offset, p.Line, p.Col); got != want {
+					t.Errorf("Pos(%d) = %s, want %s", off, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceLineStartPanics(t *testing.T) {
+	src := scanSource("a\nb")
+	for _, line := range []int{0, 3} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("LineStart(%d) did not panic", line)
+				}
+			}()
+			src.LineStart(line)
+		}()
+	}
+}
+
+func TestSourceAddLine(t *testing.T) {
+	src := NewSource("")
+	for _, off := range []int{4, 2, 4, 9} {
+		src.AddLine(off)
+	}
+	if want := []int{0, 4, 9}; !slices.Equal(src.lines, want) {
+		t.Errorf("lines = %v, want %v", src.lines, want)
+	}
+}
+
+// TestSourceItems checks that the positions computed by Source agree
+// with the positions of the items.
+func TestSourceItems(t *testing.T) {
+	inputs := append(slices.Clone(fuzzSeeds), "\uFEFFvar a;\r\nprint a;\rx")
+	for _, input := range inputs {
+		src := NewSource("")
+		for it := range Lex(input, withSource(src), withRecovery()) {
+			if line, col := src.Position(it.start); line != it.line || col != it.col {
+				t.Errorf("%q: Position(%d) = %d:%d, want %d:%d (%s)", input, it.start, line, col, it.line, it.col, itemDesc(it))
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// loxTestDir is the directory of the test suite of Crafting
+// Interpreters. By default, the subset vendored in testdata is used.
+// To check the whole suite, run:
+//
+//	go test -run TestSuite -loxtest /path/to/craftinginterpreters/test
+var loxTestDir = flag.String("loxtest", "testdata/craftinginterpreters", "directory of the Lox test suite")
+
+// Expectations of the test suite, as in its runner, tool/bin/test.dart.
+// The errors of clox only are ignored.
+var (
+	expectOutputRe = regexp.MustCompile(`// expect: ?(.*)`)
+	expectErrorRe  = regexp.MustCompile(`// (Error.*)`)
+	errorLineRe    = regexp.MustCompile(`// \[((java|c) )?line (\d+)\] (Error.*)`)
+)
+
+// suiteSkipDirs are the directories of the test suite whose tests do
+// not apply to jlox: the limits of clox, the benchmarks and the
+// expressions of the chapters before statements.
+var suiteSkipDirs = []string{"benchmark", "expressions", "limit"}
+
+// TestSuite checks the lexer and the parser against the expectations
+// of the test suite of Crafting Interpreters. The tests of the
+// scanning directory compare the tokens with those printed by the
+// scanner of jlox. The other tests compare the lines of the lexical,
+// syntax and resolution errors with those of the errors expected, but
+// not the messages, which are worded differently.
+func TestSuite(t *testing.T) {
+	root := *loxTestDir
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			if slices.Contains(suiteSkipDirs, name) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".lox" {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(src), "// nontest") {
+			return nil
+		}
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			if filepath.Base(filepath.Dir(name)) == "scanning" {
+				checkScanning(t, string(src))
+			} else {
+				checkErrors(t, string(src))
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkScanning checks that the tokens of src are those expected.
+func checkScanning(t *testing.T, src string) {
+	var want []string
+	for _, m := range expectOutputRe.FindAllStringSubmatch(src, -1) {
+		want = append(want, m[1])
+	}
+	var got []string
+	for it := range Lex(src) {
+		got = append(got, jloxToken(it))
+		if it.typ == itemEOF || it.typ == itemError {
+			break
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got tokens:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// jloxToken returns it as printed by the scanner of jlox, such as
+// "NUMBER 123 123.0".
+func jloxToken(it item) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range it.typ.String() {
+		if unicode.IsLower(prev) && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	lit := "null"
+	switch it.typ {
+	case itemString:
+		lit = it.lit
+	case itemNumber:
+		f, _ := strconv.ParseFloat(it.lit, 64)
+		lit = javaDouble(f)
+	}
+	return b.String() + " " + it.Val() + " " + lit
+}
+
+// javaDouble formats f as Double.toString of Java does.
+func javaDouble(f float64) string {
+	if abs := math.Abs(f); abs == 0 || abs >= 1e-3 && abs < 1e7 {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	mant, exp, _ := strings.Cut(strconv.FormatFloat(f, 'E', -1, 64), "E")
+	if !strings.Contains(mant, ".") {
+		mant += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mant + "E" + strconv.Itoa(e)
+}
+
+// checkErrors checks that the errors of src are reported at the lines
+// expected. As in jlox, the program is only resolved if it is
+// syntactically correct.
+func checkErrors(t *testing.T, src string) {
+	var want []int
+	for i, line := range strings.Split(src, "\n") {
+		if m := errorLineRe.FindStringSubmatch(line); m != nil {
+			if m[2] != "c" {
+				n, _ := strconv.Atoi(m[3])
+				want = append(want, n)
+			}
+		} else if expectErrorRe.MatchString(line) {
+			want = append(want, i+1)
+		}
+	}
+
+	var got []int
+	stmts, diags := parse(src)
+	if len(diags) == 0 {
+		diags = analyze(stmts)
+	}
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			got = append(got, d.Pos.Line)
+		}
+	}
+
+	slices.Sort(want)
+	slices.Sort(got)
+	want = slices.Compact(want)
+	got = slices.Compact(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("got errors at lines %v, want %v", got, want)
+		for _, d := range diags {
+			t.Log(d)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"text/template"
+)
+
+// templateItem is the data passed to the templates of the -template
+// flag for every item.
+type templateItem struct {
+	File     string // Name of the input.
+	Type     string // Type, such as Identifier.
+	Category string // Category, such as keyword.
+	Val      string // Text of the item in the input, or the error message.
+	Lit      string // Literal value of strings and numbers.
+	Line     int    // Line number, starting at 1.
+	Col      int    // Byte column, starting at 1.
+	Start    int    // Byte offset of the start, starting at 0.
+	End      int    // Byte offset of the end.
+}
+
+// templateFuncs are the functions available to the templates of the
+// -template flag, besides the predefined ones.
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+}
+
+// templatePrinter prints every item by executing a template, followed
+// by a newline.
+type templatePrinter struct {
+	w    *bufio.Writer
+	tmpl *template.Template
+	name string // name of the current input.
+}
+
+// newTemplatePrinter returns a templatePrinter that writes to w the
+// output of the template text. The fields of the template data are
+// those of templateItem.
+func newTemplatePrinter(w io.Writer, text string) (*templatePrinter, error) {
+	tmpl, err := template.New("item").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &templatePrinter{w: bufio.NewWriter(w), tmpl: tmpl}, nil
+}
+
+func (p *templatePrinter) start(name string) error {
+	p.name = name
+	return nil
+}
+
+func (p *templatePrinter) print(it item) error {
+	data := templateItem{
+		File:     p.name,
+		Type:     it.typ.String(),
+		Category: it.typ.Category().String(),
+		Val:      it.Val(),
+		Lit:      it.lit,
+		Line:     it.line,
+		Col:      it.col,
+		Start:    it.start,
+		End:      it.end,
+	}
+	if err := p.tmpl.Execute(p.w, data); err != nil {
+		return err
+	}
+	return p.w.WriteByte('\n')
+}
+
+func (p *templatePrinter) close() error {
+	return p.w.Flush()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jroimartin/poc/loxlex/ast"
+)
+
+// Diagnostic codes of the reachability analysis.
+const (
+	CodeUnreachable = "LOX2009"
+)
+
+// unreachable returns warnings about the code of stmts that can never
+// run: the statements of a block after one that always returns, and
+// the bodies of the loops whose condition is a false literal, such as
+// while (false).
+//
+// The analysis is purely syntactic, so conditions that are constant
+// but not literals are not detected unless they are folded first.
+func unreachable(stmts []ast.Stmt) []Diagnostic {
+	var diags []Diagnostic
+	warn := func(pos, end ast.Pos, format string, args ...any) {
+		diags = append(diags, Diagnostic{
+			Pos:      position(pos),
+			End:      position(end),
+			Severity: SeverityWarning,
+			Code:     CodeUnreachable,
+			Msg:      fmt.Sprintf(format, args...),
+		})
+	}
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Block:
+				// The statements of the blocks made by
+				// desugaring for loops are not in the order of
+				// the source code.
+				if !n.Lbrace.IsValid() {
+					break
+				}
+				for i, s := range n.Stmts[:max(0, len(n.Stmts)-1)] {
+					if returns(s) {
+						rest := n.Stmts[i+1:]
+						warn(rest[0].Pos(), rest[len(rest)-1].End(), "unreachable code")
+						break
+					}
+				}
+			case *ast.While:
+				if isFalse(n.Cond) {
+					warn(n.Body.Pos(), n.Body.End(), "unreachable loop body: the condition is always false")
+				}
+			case *ast.For:
+				if n.Cond != nil && isFalse(n.Cond) {
+					warn(n.Body.Pos(), n.Body.End(), "unreachable loop body: the condition is always false")
+				}
+			}
+			return true
+		})
+	}
+	return diags
+}
+
+// returns reports whether the statement s always returns: a return
+// statement, a block with one, or an if statement whose branches both
+// always return.
+func returns(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.Return:
+		return true
+	case *ast.Block:
+		for _, s := range s.Stmts {
+			if returns(s) {
+				return true
+			}
+		}
+	case *ast.If:
+		return s.Else != nil && returns(s.Then) && returns(s.Else)
+	}
+	return false
+}
+
+// isFalse reports whether e is a literal that is false in a condition.
+func isFalse(e ast.Expr) bool {
+	lit, ok := e.(*ast.Literal)
+	return ok && !truthy(lit.Value)
+}
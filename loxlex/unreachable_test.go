@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnreachable(t *testing.T) {
+	tests := []struct {
+		input string
+		diags []string
+	}{
+		{"fun f() { return; }", nil},
+		{"fun f() { return 1; print 2; }", []string{"1:21: warning[LOX2009]: unreachable code"}},
+		{"fun f() { { return; } print 1; print 2; }", []string{"1:23: warning[LOX2009]: unreachable code"}},
+		{"fun f() { if (a) return; print 1; }", nil},
+		{"fun f() { if (a) return 1; else { return 2; } print 3; }", []string{"1:47: warning[LOX2009]: unreachable code"}},
+		{"fun f() { while (a) { return; } print 1; }", nil},
+		{"fun f() { for (var i = 0; i < 3; i = i + 1) { return i; } }", nil},
+		{"while (false) print 1;", []string{"1:15: warning[LOX2009]: unreachable loop body: the condition is always false"}},
+		{"while (nil) {}", []string{"1:13: warning[LOX2009]: unreachable loop body: the condition is always false"}},
+		{"while (0) {}", nil},
+		{"for (; false;) print 1;", []string{"1:16: warning[LOX2009]: unreachable loop body: the condition is always false"}},
+	}
+	for _, tt := range tests {
+		stmts, diags := parse(tt.input)
+		if len(diags) > 0 {
+			t.Fatalf("%q: unexpected diagnostics: %v", tt.input, diags)
+		}
+		var got []string
+		for _, d := range unreachable(stmts) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.diags, "\n") {
+			t.Errorf("%q: got diagnostics:\n%s\nwant:\n%s", tt.input, strings.Join(got, "\n"), strings.Join(tt.diags, "\n"))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// pollInterval is the time between checks for changes in watch mode.
+const pollInterval = 500 * time.Millisecond
+
+// fileState is the state of a file used to detect changes.
+type fileState struct {
+	name    string
+	modTime time.Time
+	size    int64
+	missing bool
+}
+
+// watchArgs lexes the files denoted by the command line arguments
+// every time any of them changes, clearing the screen first. Changes
+// are detected by polling, so it works everywhere without depending on
+// the notification mechanisms of the system. It never returns.
+func watchArgs(args []string) {
+	if slices.Contains(args, "-") {
+		fmt.Fprintln(os.Stderr, "error: cannot watch the standard input")
+		os.Exit(2)
+	}
+	var last []fileState
+	for {
+		// Expand the arguments every time, so files added to
+		// watched directories are noticed.
+		names, _, err := expandArgs(args, *recursive)
+		if err != nil {
+			names = args
+		}
+		cur := fileStates(names)
+		if !slices.Equal(cur, last) {
+			fmt.Print("\x1b[H\x1b[2J")
+			if lexArgs(args) == 2 {
+				os.Exit(2)
+			}
+			fmt.Fprintln(os.Stderr, "\nwatching for changes; press Ctrl-C to stop")
+			last = cur
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// fileStates returns the current state of the named files.
+func fileStates(names []string) []fileState {
+	states := make([]fileState, len(names))
+	for i, name := range names {
+		states[i].name = name
+		fi, err := os.Stat(name)
+		if err != nil {
+			states[i].missing = true
+			continue
+		}
+		states[i].modTime = fi.ModTime()
+		states[i].size = fi.Size()
+	}
+	return states
+}